@@ -0,0 +1,48 @@
+package tictacgo
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CellName names a board index in coordinate notation, columns a-c from left
+// to right and rows 1-3 from top to bottom, so index 0 is a1 and 4 is b2.
+func CellName(index int) string {
+	return string(rune('a'+index%3)) + strconv.Itoa(index/3+1)
+}
+
+// ParseCell returns the board index of a cell named by CellName.
+func ParseCell(name string) (int, bool) {
+	if len(name) != 2 || name[0] < 'a' || name[0] > 'c' || name[1] < '1' || name[1] > '3' {
+		return 0, false
+	}
+	return int(name[1]-'1')*3 + int(name[0]-'a'), true
+}
+
+// Result is the game's result in the usual record form: 1-0 when X won, 0-1
+// when O won, 1/2-1/2 for a tie and * while it is still going.
+func (g *Game) Result() string {
+	switch g.Winner {
+	case "X":
+		return "1-0"
+	case "O":
+		return "0-1"
+	case "tie":
+		return "1/2-1/2"
+	}
+	return "*"
+}
+
+// MoveText writes the game's moves in numbered pairs followed by the
+// result, like "1. b2 a1 2. c3 a3 3. a2 1-0".
+func (g *Game) MoveText() string {
+	var s strings.Builder
+	for i, cell := range g.History {
+		if i%2 == 0 {
+			s.WriteString(strconv.Itoa(i/2+1) + ". ")
+		}
+		s.WriteString(CellName(cell) + " ")
+	}
+	s.WriteString(g.Result())
+	return s.String()
+}
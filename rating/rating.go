@@ -0,0 +1,127 @@
+// Package rating implements Glicko-2 and Elo ratings for one game at a time.
+package rating
+
+import "math"
+
+const (
+	DefaultRating     = 1500.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+
+	glickoScale = 173.7178
+	tau         = 0.5
+	epsilon     = 0.000001
+
+	EloK = 32.0
+)
+
+type Rating struct {
+	Rating     float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+}
+
+func New() Rating {
+	return Rating{
+		Rating:     DefaultRating,
+		Deviation:  DefaultDeviation,
+		Volatility: DefaultVolatility,
+	}
+}
+
+// System updates a player's rating after a game, score is 1 for a win, 0.5
+// for a draw and 0 for a loss.
+type System func(player Rating, opponent Rating, score float64) Rating
+
+// Systems are the rating systems a server can be configured with.
+var Systems = map[string]System{
+	"glicko2": Glicko2,
+	"elo":     Elo,
+}
+
+// Glicko2 treats the game as a rating period of its own.
+func Glicko2(player Rating, opponent Rating, score float64) Rating {
+	return glicko2Period(player, []result{{opponent, score}})
+}
+
+// result is one game of a rating period.
+type result struct {
+	opponent Rating
+	score    float64
+}
+
+// glicko2Period rates player after every game in results, following steps 2
+// to 8 of Glickman's paper.
+func glicko2Period(player Rating, results []result) Rating {
+	mu := (player.Rating - DefaultRating) / glickoScale
+	phi := player.Deviation / glickoScale
+
+	var vInverse, improvement float64
+	for _, result := range results {
+		muJ := (result.opponent.Rating - DefaultRating) / glickoScale
+		phiJ := result.opponent.Deviation / glickoScale
+
+		g := 1 / math.Sqrt(1+3*phiJ*phiJ/(math.Pi*math.Pi))
+		e := 1 / (1 + math.Exp(-g*(mu-muJ)))
+		vInverse += g * g * e * (1 - e)
+		improvement += g * (result.score - e)
+	}
+	v := 1 / vInverse
+	delta := v * improvement
+
+	sigma := newVolatility(phi, player.Volatility, v, delta)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + newPhi*newPhi*improvement
+
+	return Rating{
+		Rating:     newMu*glickoScale + DefaultRating,
+		Deviation:  newPhi * glickoScale,
+		Volatility: sigma,
+	}
+}
+
+// newVolatility finds the new volatility with the Illinois algorithm from
+// step 5 of Glickman's paper.
+func newVolatility(phi float64, sigma float64, v float64, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-d)/(2*d*d) - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+
+	return math.Exp(A / 2)
+}
+
+// Elo only moves Rating, the deviation and volatility are left alone.
+func Elo(player Rating, opponent Rating, score float64) Rating {
+	expected := 1 / (1 + math.Pow(10, (opponent.Rating-player.Rating)/400))
+	player.Rating += EloK * (score - expected)
+	return player
+}
@@ -0,0 +1,52 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGlicko2Period(t *testing.T) {
+	// The worked example from Glickman's "Example of the Glicko-2 system".
+	player := Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	tests := []struct {
+		name    string
+		results []result
+		want    Rating
+	}{
+		{
+			name: "glickman",
+			results: []result{
+				{Rating{Rating: 1400, Deviation: 30}, 1},
+				{Rating{Rating: 1550, Deviation: 100}, 0},
+				{Rating{Rating: 1700, Deviation: 300}, 0},
+			},
+			want: Rating{Rating: 1464.06, Deviation: 151.52, Volatility: 0.05999},
+		},
+	}
+	for _, test := range tests {
+		got := glicko2Period(player, test.results)
+		if math.Abs(got.Rating-test.want.Rating) > 0.01 ||
+			math.Abs(got.Deviation-test.want.Deviation) > 0.01 ||
+			math.Abs(got.Volatility-test.want.Volatility) > 0.00001 {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestElo(t *testing.T) {
+	tests := []struct {
+		player, opponent, score, want float64
+	}{
+		{1500, 1500, 1, 1516},
+		{1500, 1500, 0.5, 1500},
+		{1500, 1500, 0, 1484},
+		{1600, 1200, 1, 1602.909}, // expected to score 10/11
+		{1200, 1600, 1, 1229.091},
+	}
+	for _, test := range tests {
+		got := Elo(Rating{Rating: test.player, Deviation: 100}, Rating{Rating: test.opponent}, test.score)
+		if math.Abs(got.Rating-test.want) > 0.001 || got.Deviation != 100 {
+			t.Errorf("Elo(%v vs %v, %v) = %+v, want rating %v and deviation left at 100", test.player, test.opponent, test.score, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+// Package rest is a typed client for the server's REST API, it follows the
+// OpenAPI document the server publishes at /openapi.json.
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tylerolson/tictacgo/server"
+)
+
+// Error is a structured error response from the server.
+type Error struct {
+	Status  int    // HTTP status code
+	Code    string // machine readable, like "room_exists"
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+type Client struct {
+	// BaseURL is where the REST server is, like http://127.0.0.1:8081.
+	BaseURL string
+	// Token is sent as a bearer token when set, see Login.
+	Token      string
+	HTTPClient *http.Client
+}
+
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:    baseURL,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// do sends a request with body wrapped in a Request envelope, and decodes the
+// content of the response into content if the status is want.
+func (c *Client) do(ctx context.Context, method string, path string, body any, want int, content any) error {
+	var reader io.Reader
+	if body != nil {
+		buff := &bytes.Buffer{}
+		if err := json.NewEncoder(buff).Encode(server.Request{Content: body}); err != nil {
+			return fmt.Errorf("failed to encode REST request\n%w", err)
+		}
+		reader = buff
+	}
+
+	req, err := c.newRequest(ctx, method, path, reader)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("REST server is not running\n%w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != want {
+		return decodeError(res)
+	}
+	if content == nil {
+		return nil
+	}
+
+	response := server.Response{
+		Content: content,
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to decode REST response\n%w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method string, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	return req, nil
+}
+
+func decodeError(res *http.Response) error {
+	var content server.ErrorContent
+	response := server.Response{
+		Content: &content,
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil || content.Message == "" {
+		return &Error{Status: res.StatusCode, Message: "REST server replied " + res.Status}
+	}
+
+	return &Error{Status: res.StatusCode, Code: content.Code, Message: content.Message}
+}
+
+// Info asks where the rest of the server is, see ServerInfoContent.
+func (c *Client) Info(ctx context.Context) (server.ServerInfoContent, error) {
+	var info server.ServerInfoContent
+	err := c.do(ctx, http.MethodGet, "/info", nil, http.StatusOK, &info)
+	return info, err
+}
+
+// Metrics returns the server's counters, see MetricsContent.
+func (c *Client) Metrics(ctx context.Context) (server.MetricsContent, error) {
+	var metrics server.MetricsContent
+	err := c.do(ctx, http.MethodGet, "/metrics", nil, http.StatusOK, &metrics)
+	return metrics, err
+}
+
+func (c *Client) Register(ctx context.Context, username string, password string) error {
+	body := server.CredentialsContent{Username: username, Password: password}
+	return c.do(ctx, http.MethodPost, "/register", body, http.StatusCreated, nil)
+}
+
+// Login returns a session and uses its token for every request after it.
+func (c *Client) Login(ctx context.Context, username string, password string) (server.SessionContent, error) {
+	var session server.SessionContent
+	body := server.CredentialsContent{Username: username, Password: password}
+	if err := c.do(ctx, http.MethodPost, "/login", body, http.StatusOK, &session); err != nil {
+		return session, err
+	}
+
+	c.Token = session.Token
+	return session, nil
+}
+
+func (c *Client) Leaderboard(ctx context.Context, page int, pageSize int) (server.LeaderboardContent, error) {
+	var content server.LeaderboardContent
+	path := fmt.Sprintf("/leaderboard?page=%d&pageSize=%d", page, pageSize)
+	err := c.do(ctx, http.MethodGet, path, nil, http.StatusOK, &content)
+	return content, err
+}
+
+func (c *Client) RatingHistory(ctx context.Context, username string) ([]server.RatingChange, error) {
+	var history []server.RatingChange
+	err := c.do(ctx, http.MethodGet, "/accounts/"+url.PathEscape(username)+"/ratings", nil, http.StatusOK, &history)
+	return history, err
+}
+
+// GamesQuery filters the game archive, zero fields aren't filtered on.
+type GamesQuery struct {
+	Player   string
+	Variant  string
+	Result   string // x, o or draw
+	From     time.Time
+	To       time.Time
+	Page     int
+	PageSize int
+}
+
+func (c *Client) Games(ctx context.Context, q GamesQuery) (server.GameListContent, error) {
+	query := url.Values{}
+	set := func(name string, value string) {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	set("player", q.Player)
+	set("variant", q.Variant)
+	set("result", q.Result)
+	if !q.From.IsZero() {
+		query.Set("from", q.From.Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		query.Set("to", q.To.Format(time.RFC3339))
+	}
+	if q.Page > 0 {
+		query.Set("page", strconv.Itoa(q.Page))
+	}
+	if q.PageSize > 0 {
+		query.Set("pageSize", strconv.Itoa(q.PageSize))
+	}
+
+	var content server.GameListContent
+	err := c.do(ctx, http.MethodGet, "/games?"+query.Encode(), nil, http.StatusOK, &content)
+	return content, err
+}
+
+func (c *Client) Game(ctx context.Context, id string) (server.GameDetailContent, error) {
+	var content server.GameDetailContent
+	err := c.do(ctx, http.MethodGet, "/games/"+url.PathEscape(id), nil, http.StatusOK, &content)
+	return content, err
+}
+
+// Rooms lists the public rooms.
+func (c *Client) Rooms(ctx context.Context) ([]server.RoomResponse, error) {
+	var rooms []server.RoomResponse
+	err := c.do(ctx, http.MethodGet, "/rooms", nil, http.StatusOK, &rooms)
+	return rooms, err
+}
+
+// CreateRoom makes a room with the options in content, the server picks a
+// name if content.Room is empty.
+func (c *Client) CreateRoom(ctx context.Context, content server.RoomContent) (server.CreatedRoomResponse, error) {
+	var created server.CreatedRoomResponse
+	err := c.do(ctx, http.MethodPost, "/rooms", content, http.StatusCreated, &created)
+	return created, err
+}
+
+func (c *Client) DeleteRoom(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/rooms/"+url.PathEscape(name), nil, http.StatusNoContent, nil)
+}
+
+func (c *Client) Spectators(ctx context.Context, room string) ([]server.SpectatorResponse, error) {
+	var spectators []server.SpectatorResponse
+	err := c.do(ctx, http.MethodGet, "/rooms/"+url.PathEscape(room)+"/spectators", nil, http.StatusOK, &spectators)
+	return spectators, err
+}
+
+func (c *Client) RoomLog(ctx context.Context, room string) ([]server.Event, error) {
+	var events []server.Event
+	err := c.do(ctx, http.MethodGet, "/rooms/"+url.PathEscape(room)+"/log", nil, http.StatusOK, &events)
+	return events, err
+}
+
+// JoinRoom takes a seat, code or password are only needed for private rooms.
+// The returned token is needed to move and is held for a few minutes between
+// requests.
+func (c *Client) JoinRoom(ctx context.Context, room string, code string, password string) (server.AssignMarkContent, error) {
+	var seat server.AssignMarkContent
+	body := server.RoomContent{Code: code, Password: password}
+	err := c.do(ctx, http.MethodPost, "/rooms/"+url.PathEscape(room)+"/join", body, http.StatusCreated, &seat)
+	return seat, err
+}
+
+func (c *Client) Move(ctx context.Context, room string, token string, move string) (server.GameStateContent, error) {
+	var state server.GameStateContent
+	body := server.MakeMoveContent{Move: move, Token: token}
+	err := c.do(ctx, http.MethodPost, "/rooms/"+url.PathEscape(room)+"/moves", body, http.StatusOK, &state)
+	return state, err
+}
+
+// StateQuery picks what State waits for and how it gets into a private room.
+type StateQuery struct {
+	Since int           // wait for a version after this one, -1 returns straight away
+	Wait  time.Duration // longest to wait, the server's default if zero
+	Token string        // seat token, or
+	Code  string        // invite code for private rooms
+}
+
+// State reads a room's game, long-polling when q.Since is 0 or more.
+func (c *Client) State(ctx context.Context, room string, q StateQuery) (server.GameStateContent, error) {
+	query := url.Values{}
+	if q.Since >= 0 {
+		query.Set("since", strconv.Itoa(q.Since))
+	}
+	if q.Wait > 0 {
+		query.Set("wait", q.Wait.String())
+	}
+	if q.Token != "" {
+		query.Set("token", q.Token)
+	}
+	if q.Code != "" {
+		query.Set("code", q.Code)
+	}
+
+	var state server.GameStateContent
+	err := c.do(ctx, http.MethodGet, "/rooms/"+url.PathEscape(room)+"/state?"+query.Encode(), nil, http.StatusOK, &state)
+	return state, err
+}
+
+// Events opens the lobby's server-sent event stream, the caller reads and
+// closes the body.
+func (c *Client) Events(ctx context.Context) (io.ReadCloser, error) {
+	return c.stream(ctx, "/events")
+}
+
+// RoomEvents opens a room's server-sent event stream.
+func (c *Client) RoomEvents(ctx context.Context, room string, code string) (io.ReadCloser, error) {
+	path := "/rooms/" + url.PathEscape(room) + "/events"
+	if code != "" {
+		path += "?code=" + url.QueryEscape(code)
+	}
+	return c.stream(ctx, path)
+}
+
+func (c *Client) stream(ctx context.Context, path string) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("REST server is not running\n%w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return nil, decodeError(res)
+	}
+
+	return res.Body, nil
+}
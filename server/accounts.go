@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tylerolson/tictacgo/rating"
+	"github.com/tylerolson/tictacgo/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	DefaultTokenTTL   = 24 * time.Hour
+	MinPasswordLength = 8
+)
+
+var (
+	ErrUsernameTaken      = errors.New("that username is taken")
+	ErrInvalidUsername    = errors.New("usernames must be 3-20 letters, digits or '_'")
+	ErrInvalidPassword    = errors.New("passwords must be at least 8 characters")
+	ErrInvalidCredentials = errors.New("wrong username or password")
+	ErrInvalidToken       = errors.New("session token is invalid or expired")
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,20}$`)
+
+// Account is a registered player, as kept in the server's store.
+type Account = store.User
+
+type usernameKey struct{}
+
+// Register creates an account with a bcrypt hashed password.
+func (s *Server) Register(username string, password string) error {
+	if !usernamePattern.MatchString(username) {
+		return ErrInvalidUsername
+	}
+	if len(password) < MinPasswordLength {
+		return ErrInvalidPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return ErrInvalidPassword
+	}
+
+	err = s.store.CreateUser(Account{
+		Username:     username,
+		PasswordHash: hash,
+		Created:      time.Now(),
+		Rating:       rating.New(),
+	})
+	if errors.Is(err, store.ErrExists) {
+		return ErrUsernameTaken
+	} else if err != nil {
+		return fmt.Errorf("couldn't save account\n%w", err)
+	}
+
+	log.Info().Str("username", username).Msg("Registered account")
+	return nil
+}
+
+// Login checks an account's password and issues a signed session token.
+func (s *Server) Login(username string, password string) (SessionContent, error) {
+	account, err := s.store.GetUser(username)
+	if err != nil || bcrypt.CompareHashAndPassword(account.PasswordHash, []byte(password)) != nil {
+		return SessionContent{}, ErrInvalidCredentials
+	}
+
+	expires := time.Now().Add(s.TokenTTL)
+	return SessionContent{
+		Username: account.Username,
+		Token:    s.signToken(account.Username, expires),
+		Expires:  expires,
+	}, nil
+}
+
+// signToken returns base64(username|expiry).base64(hmac) signed with TokenSecret.
+func (s *Server) signToken(username string, expires time.Time) string {
+	payload := username + "|" + strconv.FormatInt(expires.Unix(), 10)
+	mac := hmac.New(sha256.New, s.TokenSecret)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyToken returns the username a session token was issued to.
+func (s *Server) VerifyToken(token string) (string, error) {
+	encodedPayload, encodedMac, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(encodedMac)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	mac := hmac.New(sha256.New, s.TokenSecret)
+	mac.Write(payload)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return "", ErrInvalidToken
+	}
+
+	username, expiry, ok := strings.Cut(string(payload), "|")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || time.Now().After(time.Unix(unix, 0)) {
+		return "", ErrInvalidToken
+	}
+
+	return username, nil
+}
+
+func newTokenSecret() []byte {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// withAuth checks the bearer token on a REST request and puts the username in
+// its context. Requests without a token get through unless required is set
+// or the server has RequireAuth set.
+func (s *Server) withAuth(required bool, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			if required || s.RequireAuth {
+				writeError(w, http.StatusUnauthorized, "unauthorized", "A bearer token is required")
+				return
+			}
+			handler(w, r)
+			return
+		}
+
+		username, err := s.VerifyToken(token)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, "unauthorized", err.Error())
+			return
+		}
+
+		handler(w, r.WithContext(context.WithValue(r.Context(), usernameKey{}, username)))
+	}
+}
+
+// requestUsername returns the username withAuth found on r, if any.
+func requestUsername(r *http.Request) string {
+	username, _ := r.Context().Value(usernameKey{}).(string)
+	return username
+}
+
+func (s *Server) postRegister(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST /register request")
+
+	content, ok := s.decodeCredentials(w, r)
+	if !ok {
+		return
+	}
+
+	err := s.Register(content.Username, content.Password)
+	switch {
+	case errors.Is(err, ErrUsernameTaken):
+		writeError(w, http.StatusConflict, "username_taken", err.Error())
+		return
+	case errors.Is(err, ErrInvalidUsername), errors.Is(err, ErrInvalidPassword):
+		writeError(w, http.StatusBadRequest, "invalid_account", err.Error())
+		return
+	case err != nil:
+		log.Error().Err(err).Msg("Failed to register account")
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to save account")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) postLogin(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST /login request")
+
+	content, ok := s.decodeCredentials(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := s.Login(content.Username, content.Password)
+	if err != nil {
+		log.Info().Str("username", content.Username).Msg("Failed login")
+		writeError(w, http.StatusUnauthorized, "invalid_credentials", err.Error())
+		return
+	}
+
+	response := Response{
+		Type:    LoggedIn,
+		Content: session,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to encode response")
+	}
+}
+
+func (s *Server) decodeCredentials(w http.ResponseWriter, r *http.Request) (CredentialsContent, bool) {
+	var content CredentialsContent
+	request := Request{
+		Content: &content,
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.writeBodyError(w, r, err)
+		return content, false
+	}
+
+	return content, true
+}
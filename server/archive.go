@@ -0,0 +1,35 @@
+package server
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/tylerolson/tictacgo/store"
+)
+
+// GameRecord is a finished game kept after its room has moved on.
+type GameRecord = store.GameRecord
+
+// archiveGame queues room's game to be stored if it has finished and hasn't
+// been archived yet, it must be called with s.mu held.
+func (s *Server) archiveGame(room *Room) {
+	if !room.game.HasWinner() || room.archived {
+		return
+	}
+
+	record := GameRecord{
+		Room:        room.name,
+		Variant:     Variants[0],
+		TimeControl: room.options.TimeControl.String(),
+		Players:     room.usernames(),
+		Game:        *room.game, // finished games don't change, sharing its slices is safe
+		Finished:    room.finishedAt,
+	}
+	room.archived = true
+	s.queueWrite(func() {
+		id, err := s.store.SaveGame(record)
+		if err != nil {
+			log.Error().Err(err).Str("room", record.Room).Msg("Failed to archive game")
+			return
+		}
+		log.Info().Str("event", "game_archived").Str("room", record.Room).Str("id", id).Str("winner", record.Game.Winner).Msg("Archived game")
+	})
+}
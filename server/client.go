@@ -1,21 +1,48 @@
 package server
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/tylerolson/tictacgo"
 )
 
+const (
+	reconnectAttempts   = 8
+	reconnectBackoff    = 250 * time.Millisecond
+	reconnectMaxBackoff = 5 * time.Second
+)
+
 type Client struct {
 	Player string
 	Game   *tictacgo.Game
 
 	roomName      string
+	token         string
+	authToken     string
+	players       map[string]string
+	ratings       map[string]int
+	address       string
+	tlsConfig     *tls.Config
 	started       bool
+	spectating    bool
+	drawOffer     string
+	rematchOffer  string
+	takebackOffer string
+	closedReason  string
+	clocks        map[string]time.Duration
+	clocksAt      time.Time
+	reconnecting  bool
+	shuttingDown  bool // the server said it is shutting down
+	rtt           time.Duration
+	readTimeout   time.Duration // from the server's heartbeats, 0 until the first
+	closed        bool
+	mu            sync.Mutex
 	conn          net.Conn
 	updateChannel chan Response
 	errorChannel  chan error
@@ -33,6 +60,91 @@ func (c *Client) IsStarted() bool {
 	return c.started
 }
 
+func (c *Client) IsSpectating() bool {
+	return c.spectating
+}
+
+// DrawOffer returns the mark of the player offering a draw, if any.
+func (c *Client) DrawOffer() string {
+	return c.drawOffer
+}
+
+// RematchOffer returns the mark of the player asking for a rematch, if any.
+func (c *Client) RematchOffer() string {
+	return c.rematchOffer
+}
+
+// TakebackOffer returns the mark of the player asking to undo their move, if any.
+func (c *Client) TakebackOffer() string {
+	return c.takebackOffer
+}
+
+// Clock returns how long mark has left, counting down locally since the last
+// update for the player on move. It returns false if the room has no clock.
+func (c *Client) Clock(mark string) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	left, ok := c.clocks[mark]
+	if !ok {
+		return 0, false
+	}
+
+	if c.started && !c.Game.HasWinner() && c.Game.Turn == mark {
+		left -= time.Since(c.clocksAt)
+	}
+	return max(left, 0), true
+}
+
+// ClosedReason returns why the server closed the room, or "" if it is open.
+func (c *Client) ClosedReason() string {
+	return c.closedReason
+}
+
+// SetAuthToken sets the login session token sent when connecting.
+func (c *Client) SetAuthToken(token string) {
+	c.authToken = token
+}
+
+// SetTLSConfig makes the client connect over TLS with config, see
+// ClientTLSConfig. It has to be set before EstablishConnection.
+func (c *Client) SetTLSConfig(config *tls.Config) {
+	c.tlsConfig = config
+}
+
+// Username returns the username of the player with mark, or "" for a guest.
+func (c *Client) Username(mark string) string {
+	return c.players[mark]
+}
+
+// Rating returns the rating of the logged in player with mark.
+func (c *Client) Rating(mark string) (int, bool) {
+	rating, ok := c.ratings[mark]
+	return rating, ok
+}
+
+func (c *Client) IsReconnecting() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reconnecting
+}
+
+// ServerShuttingDown reports whether the server said it is shutting down
+// since the client last took its seat.
+func (c *Client) ServerShuttingDown() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.shuttingDown
+}
+
+// Latency returns the round trip to the server measured by the last
+// heartbeat, it returns false before the first one.
+func (c *Client) Latency() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rtt, c.rtt > 0
+}
+
 func NewClient() *Client {
 	g := tictacgo.NewGame()
 	return &Client{
@@ -47,35 +159,134 @@ func NewClient() *Client {
 }
 
 func (c *Client) EstablishConnection(ip string) error {
-	conn, err := net.Dial("tcp", ip)
+	conn, err := c.dial(ip)
 	if err != nil {
-		return fmt.Errorf("couldn't dial tcp\n%w", err)
+		return err
 	}
 
+	c.address = ip
 	c.conn = conn
 
+	if err := c.authenticate(); err != nil {
+		conn.Close()
+		return err
+	}
+
 	go c.receiveResponse()
 	return nil
 }
 
+// dial connects to the game server at address, over TLS if the client has a
+// TLS config.
+func (c *Client) dial(address string) (net.Conn, error) {
+	if c.tlsConfig == nil {
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't dial tcp\n%w", err)
+		}
+		return conn, nil
+	}
+
+	conn, err := tls.Dial("tcp", address, c.tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't dial tls\n%w", err)
+	}
+	return conn, nil
+}
+
+// authenticate sends the login session token, if there is one, on the current connection.
+func (c *Client) authenticate() error {
+	if c.authToken == "" {
+		return nil
+	}
+
+	err := c.send(Request{
+		Type: Authenticate,
+		Content: AuthenticateContent{
+			Token: c.authToken,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("err sending Authenticate\n%w", err)
+	}
+
+	return nil
+}
+
 func (c *Client) CloseConnection() {
-	c.conn.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+func (c *Client) getConn() net.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn
+}
+
+func (c *Client) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *Client) send(request Request) error {
+	conn := c.getConn()
+	if conn == nil {
+		return errors.New("client connection is nil")
+	}
+	return json.NewEncoder(conn).Encode(request)
 }
 
 func (c *Client) receiveResponse() {
+	decoder := json.NewDecoder(c.getConn())
+
 	for {
 		var rawContent json.RawMessage
 		response := Response{
 			Content: &rawContent,
 		}
 
-		if err := json.NewDecoder(c.conn).Decode(&response); err != nil {
-			if errors.Is(err, net.ErrClosed) {
+		c.mu.Lock()
+		if c.readTimeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+		}
+		c.mu.Unlock()
+
+		if err := decoder.Decode(&response); err != nil {
+			if errors.Is(err, net.ErrClosed) || c.isClosed() {
+				return
+			}
+
+			if isTimeout(err) {
+				c.getConn().Close() // half open, nothing more will come on it
+				err = fmt.Errorf("server stopped answering heartbeats\n%w", err)
+			}
+
+			if c.token == "" {
+				c.errorChannel <- fmt.Errorf("err decoding response\n%w", err)
 				return
 			}
 
-			c.errorChannel <- fmt.Errorf("err decoding response\n%w", err)
-			return
+			if err := c.reconnect(); err != nil {
+				c.errorChannel <- err
+				return
+			}
+			decoder = json.NewDecoder(c.getConn())
+			continue
+		}
+
+		if response.Type == Ping {
+			if err := c.handlePing(rawContent); err != nil {
+				c.errorChannel <- err
+				return
+			}
+			continue
 		}
 
 		switch response.Type {
@@ -88,6 +299,24 @@ func (c *Client) receiveResponse() {
 			}
 
 			c.Player = content.Player
+			c.token = content.Token
+			c.roomName = content.Room
+
+			c.mu.Lock()
+			c.reconnecting = false
+			c.shuttingDown = false
+			c.mu.Unlock()
+		case Spectating:
+			var content RoomContent
+
+			if err := json.Unmarshal(rawContent, &content); err != nil {
+				c.errorChannel <- fmt.Errorf("err unmarshalling SpectatingContent\n%w", err)
+				return
+			}
+
+			c.roomName = content.Room
+			c.Player = ""
+			c.spectating = true
 		case UpdateGame:
 			var content UpdateGameContent
 
@@ -97,7 +326,41 @@ func (c *Client) receiveResponse() {
 			}
 
 			c.started = content.Started
+			c.drawOffer = content.DrawOffer
+			c.rematchOffer = content.RematchOffer
+			c.takebackOffer = content.TakebackOffer
+			c.players = content.Players
+			c.ratings = content.Ratings
+
+			c.mu.Lock()
+			c.clocks = content.Clocks
+			c.clocksAt = time.Now()
+			c.mu.Unlock()
+
 			c.Game.SetGame(content.Game)
+		case RoomClosed:
+			var content RoomClosedContent
+
+			if err := json.Unmarshal(rawContent, &content); err != nil {
+				c.errorChannel <- fmt.Errorf("err unmarshalling RoomClosedContent\n%w", err)
+				return
+			}
+
+			c.closedReason = content.Reason
+			c.token = "" // nothing left to resume
+		case ShuttingDown:
+			c.mu.Lock()
+			c.shuttingDown = true
+			c.mu.Unlock()
+		case Error:
+			var content ErrorContent
+
+			if err := json.Unmarshal(rawContent, &content); err != nil {
+				c.errorChannel <- fmt.Errorf("err unmarshalling ErrorContent\n%w", err)
+				return
+			}
+
+			c.errorChannel <- errors.New(content.Message)
 		}
 
 		c.updateChannel <- response
@@ -106,8 +369,79 @@ func (c *Client) receiveResponse() {
 	}
 }
 
+// handlePing answers a heartbeat and keeps the read timeout and latency it
+// carries.
+func (c *Client) handlePing(rawContent json.RawMessage) error {
+	var content HeartbeatContent
+	if err := json.Unmarshal(rawContent, &content); err != nil {
+		return fmt.Errorf("err unmarshalling HeartbeatContent\n%w", err)
+	}
+
+	c.mu.Lock()
+	c.rtt = content.RTT
+	c.readTimeout = content.Interval * time.Duration(max(content.MaxMissed, 1))
+	c.mu.Unlock()
+
+	// if this fails the connection is gone, which the next read finds out
+	c.send(Request{Type: Pong, Content: content})
+	return nil
+}
+
+// reconnect redials the server with exponential backoff and resumes the
+// session with the token from the last AssignMark.
+func (c *Client) reconnect() error {
+	c.mu.Lock()
+	c.reconnecting = true
+	c.mu.Unlock()
+
+	c.updateChannel <- Response{Type: Reconnecting}
+
+	backoff := reconnectBackoff
+	for attempt := 0; attempt < reconnectAttempts; attempt++ {
+		time.Sleep(backoff)
+		backoff = min(backoff*2, reconnectMaxBackoff)
+
+		conn, err := c.dial(c.address)
+		if err != nil {
+			continue
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			conn.Close()
+			return net.ErrClosed
+		}
+		c.conn = conn
+		c.mu.Unlock()
+
+		if err = c.authenticate(); err != nil {
+			conn.Close()
+			continue
+		}
+
+		err = c.send(Request{
+			Type: Resume,
+			Content: ResumeContent{
+				Room:  c.roomName,
+				Token: c.token,
+			},
+		})
+		if err == nil {
+			return nil
+		}
+		conn.Close()
+	}
+
+	c.mu.Lock()
+	c.reconnecting = false
+	c.mu.Unlock()
+
+	return fmt.Errorf("couldn't reconnect after %d attempts", reconnectAttempts)
+}
+
 func (c *Client) MakeMove(move string) error {
-	err := json.NewEncoder(c.conn).Encode(Request{
+	err := c.send(Request{
 		Type: MakeMove,
 		Content: MakeMoveContent{
 			Room:   c.roomName,
@@ -123,16 +457,24 @@ func (c *Client) MakeMove(move string) error {
 }
 
 func (c *Client) JoinRoom(roomName string) error {
-	if c.conn == nil {
+	return c.JoinPrivateRoom(roomName, "", "")
+}
+
+// JoinPrivateRoom joins a private room with its invite code or password, the
+// room name can be left empty when joining by code.
+func (c *Client) JoinPrivateRoom(roomName string, code string, password string) error {
+	if c.getConn() == nil {
 		return errors.New("JoinRoom() client connection is nil")
 	}
 
 	c.roomName = roomName
 
-	err := json.NewEncoder(c.conn).Encode(Request{
+	err := c.send(Request{
 		Type: JoinRoom,
 		Content: RoomContent{
-			Room: roomName,
+			Room:     roomName,
+			Code:     code,
+			Password: password,
 		},
 	})
 
@@ -142,3 +484,105 @@ func (c *Client) JoinRoom(roomName string) error {
 
 	return nil
 }
+
+func (c *Client) SpectateRoom(roomName string) error {
+	if c.getConn() == nil {
+		return errors.New("SpectateRoom() client connection is nil")
+	}
+
+	c.roomName = roomName
+
+	err := c.send(Request{
+		Type: Spectate,
+		Content: RoomContent{
+			Room: roomName,
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("err sending Spectate\n%w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) LeaveRoom() error {
+	return c.sendRoomRequest(LeaveRoom)
+}
+
+func (c *Client) Resign() error {
+	return c.sendRoomRequest(Resign)
+}
+
+func (c *Client) OfferDraw() error {
+	return c.sendRoomRequest(OfferDraw)
+}
+
+func (c *Client) AcceptDraw() error {
+	return c.sendRoomRequest(AcceptDraw)
+}
+
+func (c *Client) DeclineDraw() error {
+	return c.sendRoomRequest(DeclineDraw)
+}
+
+func (c *Client) RequestRematch() error {
+	return c.sendRoomRequest(RequestRematch)
+}
+
+func (c *Client) AcceptRematch() error {
+	return c.sendRoomRequest(AcceptRematch)
+}
+
+func (c *Client) RequestTakeback() error {
+	return c.sendRoomRequest(RequestTakeback)
+}
+
+func (c *Client) AcceptTakeback() error {
+	return c.sendRoomRequest(AcceptTakeback)
+}
+
+func (c *Client) DeclineTakeback() error {
+	return c.sendRoomRequest(DeclineTakeback)
+}
+
+func (c *Client) sendRoomRequest(requestType RequestType) error {
+	err := c.send(Request{
+		Type: requestType,
+		Content: RoomContent{
+			Room: c.roomName,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("err sending %s\n%w", requestType, err)
+	}
+
+	return nil
+}
+
+func (c *Client) QuickMatch(variant string, timeControl string) error {
+	err := c.send(Request{
+		Type: QuickMatch,
+		Content: QuickMatchContent{
+			Variant:     variant,
+			TimeControl: timeControl,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("err sending QuickMatch\n%w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) CancelQuickMatch() error {
+	err := c.send(Request{
+		Type:    CancelQuickMatch,
+		Content: QuickMatchContent{},
+	})
+	if err != nil {
+		return fmt.Errorf("err sending CancelQuickMatch\n%w", err)
+	}
+
+	return nil
+}
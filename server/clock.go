@@ -0,0 +1,185 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeControl is a chess style clock: each player starts with Initial, gains
+// Increment after every move and, if PerMove is set, must move within it.
+type TimeControl struct {
+	Initial   time.Duration
+	Increment time.Duration
+	PerMove   time.Duration
+}
+
+// NoTimeControl asks for a game without a clock. Rooms and quick matches that
+// leave their time control empty get the server's DefaultTimeControl instead.
+const NoTimeControl = "none"
+
+// ParseTimeControl parses "<minutes>+<seconds>" with an optional
+// "/<seconds>" per move limit, e.g. "1+0", "3+2" or "5+0/30". A bare
+// "/<seconds>" only limits each move. An empty string or NoTimeControl means
+// no clock.
+func ParseTimeControl(s string) (TimeControl, error) {
+	var tc TimeControl
+	if s == "" || s == NoTimeControl {
+		return tc, nil
+	}
+
+	base, perMove, hasPerMove := strings.Cut(s, "/")
+	if hasPerMove {
+		seconds, err := strconv.ParseFloat(perMove, 64)
+		if err != nil || seconds <= 0 {
+			return tc, fmt.Errorf("invalid per move limit %q", perMove)
+		}
+		tc.PerMove = time.Duration(seconds * float64(time.Second))
+	}
+
+	if base != "" {
+		minutes, increment, ok := strings.Cut(base, "+")
+		if !ok {
+			return tc, fmt.Errorf("time control %q should look like 3+2", s)
+		}
+
+		m, err := strconv.ParseFloat(minutes, 64)
+		if err != nil || m <= 0 {
+			return tc, fmt.Errorf("invalid initial time %q", minutes)
+		}
+		i, err := strconv.ParseFloat(increment, 64)
+		if err != nil || i < 0 {
+			return tc, fmt.Errorf("invalid increment %q", increment)
+		}
+
+		tc.Initial = time.Duration(m * float64(time.Minute))
+		tc.Increment = time.Duration(i * float64(time.Second))
+	}
+
+	return tc, nil
+}
+
+func (tc TimeControl) Enabled() bool {
+	return tc.Initial > 0 || tc.PerMove > 0
+}
+
+func (tc TimeControl) String() string {
+	if !tc.Enabled() {
+		return ""
+	}
+
+	s := ""
+	if tc.Initial > 0 {
+		s = strconv.FormatFloat(tc.Initial.Minutes(), 'f', -1, 64) + "+" +
+			strconv.FormatFloat(tc.Increment.Seconds(), 'f', -1, 64)
+	}
+	if tc.PerMove > 0 {
+		s += "/" + strconv.FormatFloat(tc.PerMove.Seconds(), 'f', -1, 64)
+	}
+	return s
+}
+
+// startClock resets both clocks and starts the one of the player on move,
+// onFlag is called from another goroutine when that player may have run out.
+func (r *Room) startClock(onFlag func()) {
+	if !r.options.TimeControl.Enabled() {
+		return
+	}
+
+	r.onFlag = onFlag
+	r.clocks = map[string]time.Duration{
+		"X": r.options.TimeControl.Initial,
+		"O": r.options.TimeControl.Initial,
+	}
+	r.clockRunning = true
+	r.restartTurn()
+}
+
+func (r *Room) stopClock() {
+	if !r.clockRunning {
+		return
+	}
+
+	r.chargeClock()
+	r.clockRunning = false
+	if r.flagTimer != nil {
+		r.flagTimer.Stop()
+		r.flagTimer = nil
+	}
+}
+
+// chargeClock deducts the time since the turn started from the player on
+// move, it returns false if they have run out.
+func (r *Room) chargeClock() bool {
+	if !r.clockRunning {
+		return true
+	}
+
+	flagged := r.timeLeft(r.game.Turn) <= 0
+	if r.options.TimeControl.Initial > 0 {
+		r.clocks[r.game.Turn] -= time.Since(r.turnStarted)
+	}
+	r.turnStarted = time.Now()
+
+	return !flagged
+}
+
+// addIncrement credits the player who just moved.
+func (r *Room) addIncrement(mark string) {
+	if r.clockRunning && r.options.TimeControl.Initial > 0 {
+		r.clocks[mark] += r.options.TimeControl.Increment
+	}
+}
+
+// restartTurn starts timing the player on move and arms the flag timer.
+func (r *Room) restartTurn() {
+	if !r.clockRunning {
+		return
+	}
+
+	r.turnStarted = time.Now()
+	if r.flagTimer != nil {
+		r.flagTimer.Stop()
+	}
+	r.flagTimer = time.AfterFunc(r.timeLeft(r.game.Turn), r.onFlag)
+}
+
+// timeLeft is how long mark has before they lose on time, counting the per
+// move limit if they are on move.
+func (r *Room) timeLeft(mark string) time.Duration {
+	tc := r.options.TimeControl
+
+	left := time.Duration(1<<63 - 1)
+	if tc.Initial > 0 {
+		left = r.clocks[mark]
+	}
+
+	if r.clockRunning && mark == r.game.Turn {
+		elapsed := time.Since(r.turnStarted)
+		left -= elapsed
+		if tc.PerMove > 0 {
+			left = min(left, tc.PerMove-elapsed)
+		}
+	}
+
+	return left
+}
+
+// clockContent returns both players' remaining time, or nil without a clock.
+// With only a per move limit the player waiting shows the full limit.
+func (r *Room) clockContent() map[string]time.Duration {
+	if r.clocks == nil {
+		return nil
+	}
+
+	clocks := make(map[string]time.Duration, len(r.clocks))
+	for mark := range r.clocks {
+		if r.options.TimeControl.Initial == 0 && (mark != r.game.Turn || !r.clockRunning) {
+			clocks[mark] = r.options.TimeControl.PerMove
+			continue
+		}
+		clocks[mark] = max(r.timeLeft(mark), 0)
+	}
+	return clocks
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseTimeControl(t *testing.T) {
+	tests := []struct {
+		s    string
+		want TimeControl
+	}{
+		{"", TimeControl{}},
+		{NoTimeControl, TimeControl{}},
+		{"3+2", TimeControl{Initial: 3 * time.Minute, Increment: 2 * time.Second}},
+		{"5+0/30", TimeControl{Initial: 5 * time.Minute, PerMove: 30 * time.Second}},
+		{"/10", TimeControl{PerMove: 10 * time.Second}},
+	}
+	for _, test := range tests {
+		got, err := ParseTimeControl(test.s)
+		if err != nil || got != test.want {
+			t.Errorf("ParseTimeControl(%q) = %+v, %v, want %+v", test.s, got, err, test.want)
+		}
+	}
+
+	for _, s := range []string{"3", "x+2", "3+-1", "/0", "nothing"} {
+		if _, err := ParseTimeControl(s); err == nil {
+			t.Errorf("ParseTimeControl(%q) didn't fail", s)
+		}
+	}
+}
+
+func TestNoTimeControlOverridesDefault(t *testing.T) {
+	s := NewServer()
+	s.DefaultTimeControl = TimeControl{Initial: 3 * time.Minute, Increment: 2 * time.Second}
+	router := s.routes()
+
+	for timeControl, want := range map[string]string{"": "3+2", NoTimeControl: "", "1+0": "1+0"} {
+		body, err := json.Marshal(Request{Content: RoomContent{TimeControl: timeControl}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/rooms", bytes.NewReader(body)))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("POST /rooms with %q = %d: %s", timeControl, w.Code, w.Body)
+		}
+
+		var created CreatedRoomResponse
+		if err := json.NewDecoder(w.Body).Decode(&Response{Content: &created}); err != nil {
+			t.Fatal(err)
+		}
+		if got := s.GetRoom(created.Name).options.TimeControl.String(); got != want {
+			t.Errorf("room made with %q has time control %q, want %q", timeControl, got, want)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tylerolson/tictacgo"
+)
+
+type EventType string
+
+const (
+	RoomCreatedEvent       EventType = "room_created"
+	PlayerJoinedEvent      EventType = "player_joined"
+	PlayerLeftEvent        EventType = "player_left"
+	MoveMadeEvent          EventType = "move_made"
+	ResignedEvent          EventType = "resigned"
+	TimedOutEvent          EventType = "timed_out"
+	DrawOfferedEvent       EventType = "draw_offered"
+	DrawAgreedEvent        EventType = "draw_agreed"
+	DrawDeclinedEvent      EventType = "draw_declined"
+	TakebackRequestedEvent EventType = "takeback_requested"
+	TakebackAcceptedEvent  EventType = "takeback_accepted"
+	TakebackDeclinedEvent  EventType = "takeback_declined"
+	RematchRequestedEvent  EventType = "rematch_requested"
+	RematchStartedEvent    EventType = "rematch_started"
+	RoomClosedEvent        EventType = "room_closed"
+)
+
+// Event is one entry in a room's append-only log. Which fields are set
+// depends on Type.
+type Event struct {
+	Seq      int       `json:"seq"`
+	Type     EventType `json:"type"`
+	Time     time.Time `json:"time"`
+	Room     string    `json:"room"`
+	Mark     string    `json:"mark,omitempty"`
+	Username string    `json:"username,omitempty"`
+	Move     string    `json:"move,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+
+	// Set on RoomCreatedEvent.
+	Ranked           bool   `json:"ranked,omitempty"`
+	DisableTakebacks bool   `json:"disableTakebacks,omitempty"`
+	TimeControl      string `json:"timeControl,omitempty"`
+	Private          bool   `json:"private,omitempty"`
+}
+
+// RoomState is everything about a room that can be rebuilt from its events.
+// Clocks are left out since they depend on when events are replayed.
+type RoomState struct {
+	Name             string
+	Ranked           bool
+	DisableTakebacks bool
+	TimeControl      string
+	Private          bool
+	Game             tictacgo.Game
+	Started          bool
+	Seats            map[string]string // username of the player holding each mark
+	DrawOffer        string
+	RematchOffer     string
+	TakebackOffer    string
+	Closed           bool
+	ClosedReason     string
+}
+
+// record appends event to the room's log.
+func (r *Room) record(event Event) {
+	event.Seq = len(r.events) + 1
+	event.Time = time.Now()
+	event.Room = r.name
+	r.events = append(r.events, event)
+}
+
+// Events returns a copy of the room's log.
+func (r *Room) Events() []Event {
+	return slices.Clone(r.events)
+}
+
+// State returns the live state that replaying the room's events should give.
+func (r *Room) State() RoomState {
+	seats := make(map[string]string, len(r.players))
+	for _, player := range r.players {
+		seats[player.mark] = player.username
+	}
+
+	game := *r.game
+	game.Board = slices.Clone(game.Board)
+	game.History = slices.Clone(game.History)
+	game.Takebacks = slices.Clone(game.Takebacks)
+
+	return RoomState{
+		Name:             r.name,
+		Ranked:           r.options.Ranked,
+		DisableTakebacks: r.options.DisableTakebacks,
+		TimeControl:      r.options.TimeControl.String(),
+		Private:          r.options.Private,
+		Game:             game,
+		Started:          r.started,
+		Seats:            seats,
+		DrawOffer:        r.drawOffer,
+		RematchOffer:     r.rematchOffer,
+		TakebackOffer:    r.takebackOffer,
+	}
+}
+
+// Replay rebuilds a room's state from its events in order. It fails if the
+// events don't start with the room being created or describe an impossible
+// game.
+func Replay(events []Event) (RoomState, error) {
+	var state RoomState
+	if len(events) == 0 || events[0].Type != RoomCreatedEvent {
+		return state, fmt.Errorf("a room's events must start with %s", RoomCreatedEvent)
+	}
+
+	for i, event := range events {
+		if state.Closed {
+			return state, fmt.Errorf("event %d: %s after the room closed", event.Seq, event.Type)
+		}
+		if i > 0 && event.Type == RoomCreatedEvent {
+			return state, fmt.Errorf("event %d: room created twice", event.Seq)
+		}
+
+		switch event.Type {
+		case RoomCreatedEvent:
+			state = RoomState{
+				Name:             event.Room,
+				Ranked:           event.Ranked,
+				DisableTakebacks: event.DisableTakebacks,
+				TimeControl:      event.TimeControl,
+				Private:          event.Private,
+				Game:             *tictacgo.NewGame(),
+				Seats:            make(map[string]string),
+			}
+		case PlayerJoinedEvent:
+			if _, ok := state.Seats[event.Mark]; ok {
+				return state, fmt.Errorf("event %d: %s is already seated", event.Seq, event.Mark)
+			}
+			state.Seats[event.Mark] = event.Username
+			if len(state.Seats) == 2 {
+				state.Started = true
+			}
+		case PlayerLeftEvent:
+			delete(state.Seats, event.Mark)
+			state.DrawOffer = ""
+			state.RematchOffer = ""
+			state.TakebackOffer = ""
+		case MoveMadeEvent:
+			if !state.Game.Move(event.Move) {
+				return state, fmt.Errorf("event %d: %s can't play %s", event.Seq, event.Mark, event.Move)
+			}
+			state.DrawOffer = ""
+			state.TakebackOffer = ""
+		case ResignedEvent:
+			state.Game.Resign(event.Mark)
+			state.DrawOffer = ""
+			state.TakebackOffer = ""
+		case TimedOutEvent:
+			state.Game.Timeout(event.Mark)
+			state.DrawOffer = ""
+			state.TakebackOffer = ""
+		case DrawOfferedEvent:
+			state.DrawOffer = event.Mark
+		case DrawAgreedEvent:
+			state.Game.AgreeDraw()
+			state.DrawOffer = ""
+			state.TakebackOffer = ""
+		case DrawDeclinedEvent:
+			state.DrawOffer = ""
+		case TakebackRequestedEvent:
+			state.TakebackOffer = event.Mark
+		case TakebackAcceptedEvent:
+			if !state.Game.Undo() {
+				return state, fmt.Errorf("event %d: there is no move to take back", event.Seq)
+			}
+			state.TakebackOffer = ""
+		case TakebackDeclinedEvent:
+			state.TakebackOffer = ""
+		case RematchRequestedEvent:
+			state.RematchOffer = event.Mark
+		case RematchStartedEvent:
+			seats := make(map[string]string, len(state.Seats))
+			for mark, username := range state.Seats {
+				seats[tictacgo.Opponent(mark)] = username
+			}
+			state.Seats = seats
+			state.Game = *tictacgo.NewGame()
+			state.DrawOffer = ""
+			state.RematchOffer = ""
+			state.TakebackOffer = ""
+		case RoomClosedEvent:
+			state.Closed = true
+			state.ClosedReason = event.Reason
+		default:
+			return state, fmt.Errorf("event %d: unknown type %q", event.Seq, event.Type)
+		}
+	}
+
+	return state, nil
+}
+
+// getRoomLog returns every event in the room. Private rooms need ?code= or
+// the ?token= of a seat in them.
+func (s *Server) getRoomLog(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	log.Info().Str("name", name).Msg("GET /rooms/{name}/log request")
+
+	s.mu.Lock()
+	room := s.getRoom(name)
+	if room == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "room_not_found", "Room does not exist")
+		return
+	}
+	if !canView(r, room) {
+		s.mu.Unlock()
+		writeError(w, http.StatusForbidden, "room_private", "Room is private, a valid invite code is needed")
+		return
+	}
+	events := room.Events()
+	s.mu.Unlock()
+
+	response := Response{
+		Type:    RoomLog,
+		Content: events,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to encode response")
+	}
+}
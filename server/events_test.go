@@ -0,0 +1,258 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestReplayMatchesRoom(t *testing.T) {
+	s := NewServer()
+	s.ConnectionRateLimit, s.IPRateLimit = RateLimit{}, RateLimit{}
+	room, err := s.MakeRoom("r", RoomOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := net.Pipe()
+	second, _ := net.Pipe()
+	conns := map[string]net.Conn{}
+	request := func(conn net.Conn, requestType RequestType, content any) {
+		t.Helper()
+
+		rawContent, err := json.Marshal(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.handleRequest(conn, requestType, rawContent)
+		for _, player := range room.players {
+			conns[player.mark] = player.connection
+		}
+
+		state, err := Replay(room.Events())
+		if err != nil {
+			t.Fatalf("after %s: %v", requestType, err)
+		}
+		if want := room.State(); !reflect.DeepEqual(state, want) {
+			t.Fatalf("after %s replay gave\n%+v\nwant\n%+v", requestType, state, want)
+		}
+	}
+	roomContent := RoomContent{Room: "r"}
+	move := func(mark string, cell string) {
+		t.Helper()
+		request(conns[mark], MakeMove, MakeMoveContent{Room: "r", Player: mark, Move: cell})
+	}
+
+	request(first, JoinRoom, roomContent)
+	request(second, JoinRoom, roomContent)
+	move("X", "1")
+	move("O", "5")
+	move("X", "9")
+	request(conns["X"], RequestTakeback, roomContent)
+	request(conns["O"], AcceptTakeback, roomContent)
+	move("X", "3")
+	request(conns["O"], OfferDraw, roomContent)
+	request(conns["X"], DeclineDraw, roomContent)
+	request(conns["O"], RequestTakeback, roomContent) // not O's move to take back
+	request(conns["O"], Resign, roomContent)
+	request(conns["X"], RequestRematch, roomContent)
+	request(conns["O"], AcceptRematch, roomContent)
+	if conns["X"] != second {
+		t.Fatal("rematch didn't swap marks")
+	}
+	move("X", "5")
+
+	if len(room.Events()) != 15 {
+		t.Errorf("room logged %d events, want 15", len(room.Events()))
+	}
+	if len(room.game.History) != 1 {
+		t.Errorf("rematch has %d moves, want 1", len(room.game.History))
+	}
+}
+
+func TestPrivateRoomNeedsInvite(t *testing.T) {
+	s := NewServer()
+	room, err := s.MakeRoom("secret", RoomOptions{Private: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := s.routes()
+
+	for _, path := range []string{"/rooms/secret/log", "/rooms/secret/spectators"} {
+		for query, want := range map[string]int{
+			"":                         http.StatusForbidden,
+			"?code=wrong":              http.StatusForbidden,
+			"?token=wrong":             http.StatusForbidden,
+			"?code=" + room.inviteCode: http.StatusOK,
+		} {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path+query, nil))
+			if w.Code != want {
+				t.Errorf("GET %s%s = %d, want %d", path, query, w.Code, want)
+			}
+		}
+	}
+}
+
+func TestNoTakebackAfterGameEnds(t *testing.T) {
+	for ending, end := range map[string]func(request func(mark string, requestType RequestType)){
+		"resigning": func(request func(string, RequestType)) { request("O", Resign) },
+		"a draw": func(request func(string, RequestType)) {
+			request("O", OfferDraw)
+			request("X", AcceptDraw)
+		},
+	} {
+		s := NewServer()
+		s.ConnectionRateLimit, s.IPRateLimit = RateLimit{}, RateLimit{}
+		room, err := s.MakeRoom("r", RoomOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		first, _ := net.Pipe()
+		second, _ := net.Pipe()
+		conns := map[string]net.Conn{"X": first, "O": second}
+		handle := func(conn net.Conn, requestType RequestType, content any) {
+			t.Helper()
+
+			rawContent, err := json.Marshal(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.handleRequest(conn, requestType, rawContent)
+		}
+		request := func(mark string, requestType RequestType) {
+			t.Helper()
+			handle(conns[mark], requestType, RoomContent{Room: "r"})
+		}
+
+		handle(first, JoinRoom, RoomContent{Room: "r"})
+		handle(second, JoinRoom, RoomContent{Room: "r"})
+		handle(first, MakeMove, MakeMoveContent{Room: "r", Player: "X", Move: "1"})
+		request("X", RequestTakeback)
+		end(request)
+		winner := room.game.Winner
+		request("O", AcceptTakeback)
+
+		if room.game.Winner != winner || len(room.game.History) != 1 {
+			t.Errorf("takeback after %s reopened the game: winner %q, %d moves", ending, room.game.Winner, len(room.game.History))
+		}
+		if room.takebackOffer != "" {
+			t.Errorf("takeback offer %q outlived %s", room.takebackOffer, ending)
+		}
+		state, err := Replay(room.Events())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := room.State(); !reflect.DeepEqual(state, want) {
+			t.Errorf("after %s replay gave\n%+v\nwant\n%+v", ending, state, want)
+		}
+	}
+}
+
+func TestOneSeatPerConnection(t *testing.T) {
+	s := NewServer()
+	s.ConnectionRateLimit, s.IPRateLimit = RateLimit{}, RateLimit{}
+	room, err := s.MakeRoom("r", RoomOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, _ := net.Pipe()
+	rawContent, err := json.Marshal(RoomContent{Room: "r"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.handleRequest(conn, JoinRoom, rawContent)
+	s.handleRequest(conn, JoinRoom, rawContent)
+	s.mu.Unlock()
+
+	if len(room.players) != 1 {
+		t.Errorf("one connection holds %d seats", len(room.players))
+	}
+	if room.started {
+		t.Error("the game started with one connection in both seats")
+	}
+}
+
+func TestLeavingWithdrawsOffers(t *testing.T) {
+	s := NewServer()
+	s.ConnectionRateLimit, s.IPRateLimit = RateLimit{}, RateLimit{}
+	room, err := s.MakeRoom("r", RoomOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := net.Pipe()
+	second, _ := net.Pipe()
+	request := func(conn net.Conn, requestType RequestType, content any) {
+		t.Helper()
+
+		rawContent, err := json.Marshal(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.handleRequest(conn, requestType, rawContent)
+	}
+	roomContent := RoomContent{Room: "r"}
+
+	request(first, JoinRoom, roomContent)
+	request(second, JoinRoom, roomContent)
+	request(first, MakeMove, MakeMoveContent{Room: "r", Player: "X", Move: "1"})
+	request(second, Resign, roomContent)
+	request(second, RequestRematch, roomContent)
+	request(second, LeaveRoom, roomContent)
+	request(first, AcceptRematch, roomContent)
+
+	if room.game.Winner != "X" || len(room.game.History) != 1 {
+		t.Error("a rematch started against a player who left")
+	}
+	if room.rematchOffer != "" {
+		t.Errorf("rematch offer %q outlived the player who made it", room.rematchOffer)
+	}
+	state, err := Replay(room.Events())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := room.State(); !reflect.DeepEqual(state, want) {
+		t.Errorf("replay gave\n%+v\nwant\n%+v", state, want)
+	}
+}
+
+func TestRoomStreamAdmitsSeatToken(t *testing.T) {
+	s := NewServer()
+	room, err := s.MakeRoom("secret", RoomOptions{Private: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, _ := net.Pipe()
+	player := withLock(s, func() *Player { return s.seatPlayer(room, conn, "X") })
+	server := httptest.NewServer(s.routes())
+	defer server.Close()
+
+	for query, want := range map[string]int{
+		"":                       http.StatusForbidden,
+		"?token=wrong":           http.StatusForbidden,
+		"?token=" + player.token: http.StatusOK,
+	} {
+		response, err := http.Get(server.URL + "/rooms/secret/events" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != want {
+			t.Errorf("GET /rooms/secret/events%s = %d, want %d", query, response.StatusCode, want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tylerolson/tictacgo"
+	"github.com/tylerolson/tictacgo/store"
+)
+
+const (
+	defaultGamesPageSize = 20
+	maxGamesPageSize     = 100
+)
+
+// gameFilter holds the query parameters GET /games narrows its list with.
+type gameFilter struct {
+	player  string
+	variant string
+	winner  string
+	from    time.Time
+	to      time.Time
+}
+
+func (f gameFilter) matches(game GameRecord) bool {
+	if f.player != "" && !slices.ContainsFunc([]string{game.Players["X"], game.Players["O"]}, func(username string) bool {
+		return strings.EqualFold(username, f.player)
+	}) {
+		return false
+	}
+	if f.variant != "" && game.Variant != f.variant {
+		return false
+	}
+	if f.winner != "" && game.Game.Winner != f.winner {
+		return false
+	}
+	if !f.from.IsZero() && game.Finished.Before(f.from) {
+		return false
+	}
+	if !f.to.IsZero() && !game.Finished.Before(f.to) {
+		return false
+	}
+	return true
+}
+
+// parseGameFilter reads the player, variant, result, from and to query
+// parameters. Dates are YYYY-MM-DD or RFC 3339, to is inclusive for a date.
+func parseGameFilter(r *http.Request) (gameFilter, error) {
+	query := r.URL.Query()
+	filter := gameFilter{
+		player:  query.Get("player"),
+		variant: query.Get("variant"),
+	}
+
+	if filter.variant != "" && !slices.Contains(Variants, filter.variant) {
+		return filter, fmt.Errorf("unknown variant %q", filter.variant)
+	}
+
+	switch result := query.Get("result"); strings.ToLower(result) {
+	case "":
+	case "x", "1-0":
+		filter.winner = "X"
+	case "o", "0-1":
+		filter.winner = "O"
+	case "draw", "1/2-1/2":
+		filter.winner = "tie"
+	default:
+		return filter, fmt.Errorf("result must be x, o or draw, not %q", result)
+	}
+
+	var err error
+	if filter.from, err = parseDate(query.Get("from"), false); err != nil {
+		return filter, err
+	}
+	if filter.to, err = parseDate(query.Get("to"), true); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// parseDate parses a YYYY-MM-DD date or RFC 3339 time, endOfDay moves a bare
+// date to the start of the next day.
+func parseDate(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("%q is not a YYYY-MM-DD date or RFC 3339 time", value)
+	}
+	return t, nil
+}
+
+func gameSummary(game GameRecord) GameSummary {
+	return GameSummary{
+		ID:          game.ID,
+		Room:        game.Room,
+		Variant:     game.Variant,
+		TimeControl: game.TimeControl,
+		Players:     game.Players,
+		Result:      game.Game.Result(),
+		Reason:      game.Game.Reason,
+		Plies:       len(game.Game.History),
+		Finished:    game.Finished,
+	}
+}
+
+// gameNotation writes a game record with tag pairs followed by the move text,
+// in the style of chess PGN.
+func gameNotation(game GameRecord) string {
+	player := func(mark string) string {
+		if username := game.Players[mark]; username != "" {
+			return username
+		}
+		return "?"
+	}
+
+	tags := [][2]string{
+		{"Event", "tictacgo " + game.Variant},
+		{"Site", game.Room},
+		{"Date", game.Finished.UTC().Format("2006.01.02")},
+		{"GameId", game.ID},
+		{"X", player("X")},
+		{"O", player("O")},
+		{"Result", game.Game.Result()},
+	}
+	if game.TimeControl != "" {
+		tags = append(tags, [2]string{"TimeControl", game.TimeControl})
+	}
+	if game.Game.Reason != "" {
+		tags = append(tags, [2]string{"Termination", game.Game.Reason})
+	}
+
+	var s strings.Builder
+	for _, tag := range tags {
+		s.WriteString("[" + tag[0] + " " + strconv.Quote(tag[1]) + "]\n")
+	}
+	s.WriteString("\n" + game.Game.MoveText() + "\n")
+	return s.String()
+}
+
+func (s *Server) getGames(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET /games request")
+
+	filter, err := parseGameFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	page, err := queryInt(r, "page", 1)
+	if err != nil || page < 1 {
+		writeError(w, http.StatusBadRequest, "bad_request", "page must be a positive number")
+		return
+	}
+	pageSize, err := queryInt(r, "pageSize", defaultGamesPageSize)
+	if err != nil || pageSize < 1 || pageSize > maxGamesPageSize {
+		writeError(w, http.StatusBadRequest, "bad_request", "pageSize must be between 1 and "+strconv.Itoa(maxGamesPageSize))
+		return
+	}
+
+	games, err := s.store.ListGames()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list games")
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to load games")
+		return
+	}
+
+	games = slices.DeleteFunc(games, func(game GameRecord) bool {
+		return !filter.matches(game)
+	})
+	slices.SortFunc(games, func(a, b GameRecord) int {
+		return cmp.Or(b.Finished.Compare(a.Finished), cmp.Compare(b.ID, a.ID))
+	})
+
+	content := GameListContent{
+		Page:     page,
+		PageSize: pageSize,
+		Total:    len(games),
+		Games:    make([]GameSummary, 0, pageSize),
+	}
+	for i := (page - 1) * pageSize; i < len(games) && i < page*pageSize; i++ {
+		content.Games = append(content.Games, gameSummary(games[i]))
+	}
+
+	response := Response{
+		Type:    GameList,
+		Content: content,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to encode response")
+	}
+}
+
+func (s *Server) getGame(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	log.Info().Str("id", id).Msg("GET /games/{id} request")
+
+	game, err := s.store.GetGame(id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "game_not_found", "Game does not exist")
+		return
+	} else if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to load game")
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to load game")
+		return
+	}
+
+	moves := make([]string, 0, len(game.Game.History))
+	for _, cell := range game.Game.History {
+		moves = append(moves, tictacgo.CellName(cell))
+	}
+
+	response := Response{
+		Type: GameDetail,
+		Content: GameDetailContent{
+			GameSummary: gameSummary(game),
+			Moves:       moves,
+			Notation:    gameNotation(game),
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to encode response")
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultHeartbeatInterval is how often game connections are pinged.
+	DefaultHeartbeatInterval = 10 * time.Second
+	// DefaultMaxMissedHeartbeats is how many pings can go unanswered before a
+	// connection is dropped.
+	DefaultMaxMissedHeartbeats = 3
+)
+
+// heartbeatTimeout is how long a connection can go without sending anything,
+// or 0 if heartbeats are off.
+func (s *Server) heartbeatTimeout() time.Duration {
+	if s.HeartbeatInterval <= 0 {
+		return 0
+	}
+	return s.HeartbeatInterval * time.Duration(max(s.MaxMissedHeartbeats, 1))
+}
+
+// heartbeat pings conn straight away, so the client learns the interval, then
+// every HeartbeatInterval until done is closed. The Pongs keep
+// handleConnection's read deadline from passing. Pings are only queued, a peer
+// that stopped reading is dropped by its writer or its read deadline.
+func (s *Server) heartbeat(conn net.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(s.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		s.mu.Lock()
+		s.sendMessage(conn, Ping, HeartbeatContent{
+			Sent:      time.Now(),
+			Interval:  s.HeartbeatInterval,
+			MaxMissed: s.MaxMissedHeartbeats,
+			RTT:       s.rtts[conn],
+		})
+		s.mu.Unlock()
+
+		select {
+		case <-done:
+			return
+		case <-s.closing:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handlePong records the round trip of the Ping content answers, it must be
+// called with s.mu held.
+func (s *Server) handlePong(conn net.Conn, content HeartbeatContent) {
+	if content.Sent.IsZero() {
+		return
+	}
+
+	rtt := time.Since(content.Sent)
+	s.rtts[conn] = rtt
+	log.Debug().Str("address", conn.RemoteAddr().String()).Dur("rtt", rtt).Msg("Got pong")
+}
+
+// isTimeout reports whether err is from a passed read or write deadline.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatDropsHalfOpenPeer(t *testing.T) {
+	s := NewServer()
+	s.HeartbeatInterval = 100 * time.Millisecond
+	s.MaxMissedHeartbeats = 3
+	startTestServer(t, s)
+	if _, err := s.MakeRoom("r", RoomOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient()
+	if err := c.EstablishConnection(s.TCPAddr); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.CloseConnection)
+	go func() {
+		for {
+			select {
+			case <-c.GetUpdateChannel():
+			case <-c.GetErrorChannel():
+			}
+		}
+	}()
+	if err := c.JoinRoom("r"); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, time.Second, "the client's seat", func() bool {
+		return withLock(s, func() bool { return len(s.Rooms["r"].players) == 1 })
+	})
+
+	// takes the other seat, then neither reads nor answers pings
+	peer := dialGame(t, s)
+	sendRequest(t, peer, JoinRoom, RoomContent{Room: "r"})
+
+	eventually(t, 3*time.Second, "the silent peer to be dropped", func() bool {
+		return withLock(s, func() bool {
+			room := s.Rooms["r"]
+			connected := 0
+			for _, player := range room.players {
+				if player.connected() {
+					connected++
+				} else if player.dropTimer == nil {
+					return false
+				}
+			}
+			return len(room.players) == 2 && connected == 1
+		})
+	})
+
+	time.Sleep(5 * s.HeartbeatInterval)
+	stillSeated := withLock(s, func() bool {
+		for _, player := range s.Rooms["r"].players {
+			if player.address != peer.LocalAddr().String() {
+				return player.connected()
+			}
+		}
+		return false
+	})
+	if !stillSeated {
+		t.Error("client answering heartbeats was dropped")
+	}
+	if _, ok := c.Latency(); !ok {
+		t.Error("client never measured its latency")
+	}
+}
+
+func TestStuckReaderDoesNotBlockServer(t *testing.T) {
+	s := NewServer()
+	s.HeartbeatInterval = 0
+	s.WriteTimeout = 100 * time.Millisecond
+	startTestServer(t, s)
+	if _, err := s.MakeRoom("r", RoomOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// watches the room and never reads a thing
+	peer := dialGame(t, s)
+	sendRequest(t, peer, Spectate, RoomContent{Room: "r"})
+	eventually(t, time.Second, "the spectator", func() bool {
+		return withLock(s, func() bool { return len(s.Rooms["r"].spectators) == 1 })
+	})
+
+	start := time.Now()
+	for i := 0; i < 20000; i++ {
+		withLock(s, func() any {
+			s.broadcastUpdates("r")
+			return nil
+		})
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("broadcasts took %s, a stuck reader is blocking them", elapsed)
+	}
+
+	eventually(t, 2*time.Second, "the stuck spectator to be dropped", func() bool {
+		return withLock(s, func() bool { return len(s.Rooms["r"].spectators) == 0 })
+	})
+
+	client := http.Client{Timeout: 2 * time.Second}
+	res, err := client.Get("http://" + s.RESTAddr + "/rooms")
+	if err != nil {
+		t.Fatalf("GET /rooms while a reader is stuck: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("GET /rooms = %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"net"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultRoomIdleTTL     = 5 * time.Minute
+	DefaultFinishedRoomTTL = 10 * time.Minute
+	DefaultJanitorInterval = 30 * time.Second
+)
+
+// StartJanitor periodically closes rooms that have been empty for
+// RoomIdleTTL and rooms whose games finished more than FinishedRoomTTL ago,
+// leaving permanent rooms open, and forgets full rate limit buckets, until the
+// server shuts down.
+func (s *Server) StartJanitor() {
+	ticker := time.NewTicker(s.JanitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-ticker.C:
+			s.sweepRooms()
+
+			s.mu.Lock()
+			s.sweepBuckets()
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Server) sweepRooms() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, room := range s.Rooms {
+		switch {
+		case room.options.Permanent:
+			continue
+		case room.empty() && time.Since(room.lastActive) > s.RoomIdleTTL:
+			log.Info().Str("event", "room_expired").Str("room", room.name).Dur("idle", time.Since(room.lastActive)).Msg("Janitor closing idle room")
+			s.closeRoom(room, "Room was idle")
+		case !room.finishedAt.IsZero() && time.Since(room.finishedAt) > s.FinishedRoomTTL:
+			log.Info().Str("event", "room_finished").Str("room", room.name).Msg("Janitor closing finished room")
+			s.closeRoom(room, "Game is over")
+		}
+	}
+}
+
+// closeRoom archives the room's game, tells everyone in it why it is closing
+// and removes it. It must be called with s.mu held.
+func (s *Server) closeRoom(room *Room, reason string) {
+	s.archiveGame(room)
+	room.stopClock()
+
+	content := RoomClosedContent{
+		Room:   room.name,
+		Reason: reason,
+	}
+	for _, player := range room.players {
+		if player.dropTimer != nil {
+			player.dropTimer.Stop()
+		}
+		if player.connected() {
+			s.sendMessage(player.connection, RoomClosed, content)
+		}
+	}
+	for _, spectator := range room.spectators {
+		s.sendMessage(spectator.connection, RoomClosed, content)
+	}
+
+	room.record(Event{Type: RoomClosedEvent, Reason: reason})
+	close(room.changed) // wakes long polls, which find the room gone
+	s.roomSubs[room.name].publish(RoomStreamClosed, content)
+	for ch := range s.roomSubs[room.name] {
+		close(ch)
+	}
+	delete(s.roomSubs, room.name)
+	s.publishLobby(RoomClosedSSE, room)
+	delete(s.Rooms, room.name)
+	name := room.name
+	s.queueWrite(func() {
+		if err := s.store.DeleteRoom(name); err != nil {
+			log.Error().Err(err).Str("room", name).Msg("Failed to delete stored room")
+		}
+	})
+	log.Info().Str("event", "room_closed").Str("room", room.name).Str("reason", reason).Msg("Closed room")
+}
+
+// leaveRoom gives up conn's seat or stops it spectating, a player leaving a
+// game in progress resigns it. It must be called with s.mu held.
+func (s *Server) leaveRoom(room *Room, conn net.Conn) bool {
+	if _, ok := room.spectators[conn]; ok {
+		delete(room.spectators, conn)
+		log.Info().Str("event", "spectator_left").Str("room", room.name).Msg("Spectator left room")
+		return true
+	}
+
+	player := room.playerByConn(conn)
+	if player == nil {
+		return false
+	}
+
+	if room.started && !room.game.HasWinner() {
+		room.stopClock()
+		room.game.Resign(player.mark)
+		room.record(Event{Type: ResignedEvent, Mark: player.mark})
+		if room.touch() {
+			s.rateGame(room) // rate and archive before the leaver's seat is gone
+			s.archiveGame(room)
+		}
+	}
+	delete(room.players, player.token)
+	room.clearOffers()
+	room.record(Event{Type: PlayerLeftEvent, Mark: player.mark, Username: player.username})
+	log.Info().Str("event", "player_left").Str("room", room.name).Str("mark", player.mark).Msg("Player left room")
+
+	return true
+}
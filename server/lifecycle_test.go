@@ -0,0 +1,36 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSweepRoomsKeepsPermanentRooms(t *testing.T) {
+	s := NewServer()
+	for name, options := range map[string]RoomOptions{
+		"idle":          {},
+		"finished":      {},
+		"seeded":        {Permanent: true},
+		"seeded-finish": {Permanent: true},
+	} {
+		if _, err := s.MakeRoom(name, options); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	long := time.Now().Add(-time.Hour)
+	for _, room := range s.Rooms {
+		room.lastActive = long
+	}
+	for _, name := range []string{"finished", "seeded-finish"} {
+		s.Rooms[name].game.Resign("O")
+		s.Rooms[name].finishedAt = long
+	}
+	s.sweepRooms()
+
+	for name, want := range map[string]bool{"idle": false, "finished": false, "seeded": true, "seeded-finish": true} {
+		if got := s.GetRoom(name) != nil; got != want {
+			t.Errorf("room %s open = %v after sweeping, want %v", name, got, want)
+		}
+	}
+}
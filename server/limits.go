@@ -0,0 +1,242 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	DefaultConnectionRateLimit = RateLimit{Rate: 5, Burst: 10}
+	DefaultIPRateLimit         = RateLimit{Rate: 20, Burst: 40}
+)
+
+const (
+	DefaultMaxRoomsPerClient = 5
+
+	// DefaultMaxMessageSize is the largest game request or REST body, in
+	// bytes. Every request fits in a fraction of this.
+	DefaultMaxMessageSize = 16 << 10
+
+	DefaultRESTReadHeaderTimeout = 5 * time.Second
+	DefaultRESTBodyTimeout       = 10 * time.Second
+	DefaultRESTIdleTimeout       = 2 * time.Minute
+)
+
+var errMessageTooLarge = errors.New("message is too large")
+
+// rateLimitedRequests are the requests ConnectionRateLimit and IPRateLimit
+// apply to, each has its own bucket.
+var rateLimitedRequests = map[RequestType]bool{
+	MakeRoom: true,
+	JoinRoom: true,
+	MakeMove: true,
+}
+
+// RateLimit is a token bucket, Burst requests can be made at once and the
+// bucket refills at Rate requests a second. A Rate of 0 means no limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// refill adds the tokens earned since the bucket was last used.
+func (b *bucket) refill(limit RateLimit, now time.Time) {
+	b.tokens = min(b.tokens+now.Sub(b.updated).Seconds()*limit.Rate, float64(limit.Burst))
+	b.updated = now
+}
+
+// wait is how long until the bucket has a token.
+func (b *bucket) wait(limit RateLimit) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+}
+
+type bucketKey struct {
+	ip      bool   // whether client is an IP address rather than a connection's address
+	client  string // host:port of a connection or an IP address
+	request RequestType
+}
+
+// takeToken takes a token for request from the buckets of the connection at
+// address and of its IP address. If either is empty neither is taken from,
+// and it returns how long until both have one. It must be called with s.mu
+// held.
+func (s *Server) takeToken(request RequestType, address string) (time.Duration, bool) {
+	if !rateLimitedRequests[request] {
+		return 0, true
+	}
+
+	ip := addressIP(address)
+	now := time.Now()
+	type limited struct {
+		bucket *bucket
+		limit  RateLimit
+	}
+	var buckets []limited
+	for _, key := range []bucketKey{{client: address, request: request}, {ip: true, client: ip, request: request}} {
+		limit := s.ConnectionRateLimit
+		if key.ip {
+			limit = s.IPRateLimit
+		}
+		if limit.Rate <= 0 {
+			continue
+		}
+
+		b, ok := s.buckets[key]
+		if !ok {
+			b = &bucket{tokens: float64(limit.Burst), updated: now}
+			s.buckets[key] = b
+		}
+		b.refill(limit, now)
+		buckets = append(buckets, limited{b, limit})
+	}
+
+	var wait time.Duration
+	for _, l := range buckets {
+		wait = max(wait, l.bucket.wait(l.limit))
+	}
+	if wait > 0 {
+		return wait, false
+	}
+
+	for _, l := range buckets {
+		l.bucket.tokens--
+	}
+	return 0, true
+}
+
+// sweepBuckets forgets buckets that have refilled, it must be called with
+// s.mu held.
+func (s *Server) sweepBuckets() {
+	now := time.Now()
+	for key, b := range s.buckets {
+		limit := s.ConnectionRateLimit
+		if key.ip {
+			limit = s.IPRateLimit
+		}
+
+		b.refill(limit, now)
+		if b.tokens >= float64(limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// violation counts and logs a request refused by one of the limits.
+func (s *Server) violation(code string, address string, message string) {
+	s.violations.Add(code, 1)
+	log.Warn().Str("event", "limit_exceeded").Str("code", code).Str("address", address).Msg(message)
+}
+
+// sendRateLimited tells conn to slow down, it must be called with s.mu held.
+func (s *Server) sendRateLimited(conn net.Conn, request RequestType, wait time.Duration) {
+	address := conn.RemoteAddr().String()
+	s.violation("rate_limited", address, fmt.Sprintf("Too many %s requests", request))
+	s.sendMessage(conn, Error, ErrorContent{
+		Code:       "rate_limited",
+		Message:    fmt.Sprintf("Too many %s requests, try again in %s", request, wait.Round(time.Millisecond)),
+		RetryAfter: wait.Seconds(),
+	})
+}
+
+// withRateLimit refuses request from clients that have used up their
+// tokens for it with 429 and a Retry-After header.
+func (s *Server) withRateLimit(request RequestType, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		wait, ok := s.takeToken(request, r.RemoteAddr)
+		s.mu.Unlock()
+		if ok {
+			handler(w, r)
+			return
+		}
+
+		s.violation("rate_limited", r.RemoteAddr, fmt.Sprintf("Too many %s requests", request))
+		w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+		writeError(w, http.StatusTooManyRequests, "rate_limited", fmt.Sprintf("Too many %s requests, try again in %s", request, wait.Round(time.Millisecond)))
+	}
+}
+
+// limitBodies caps how big a REST request body can be and how long the
+// client can take to send it, so slow clients can't hold connections open.
+func (s *Server) limitBodies(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != 0 {
+			if s.MaxMessageSize > 0 {
+				r.Body = http.MaxBytesReader(w, r.Body, s.MaxMessageSize)
+			}
+			if s.RESTBodyTimeout > 0 {
+				if err := http.NewResponseController(w).SetReadDeadline(time.Now().Add(s.RESTBodyTimeout)); err != nil {
+					log.Err(err).Msg("Failed to set body read deadline")
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeBodyError answers a request whose body couldn't be decoded.
+func (s *Server) writeBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		s.violation("message_too_large", r.RemoteAddr, "Request body too large")
+		writeError(w, http.StatusRequestEntityTooLarge, "message_too_large", fmt.Sprintf("Request body is over %d bytes", tooLarge.Limit))
+		return
+	}
+	if isTimeout(err) {
+		s.violation("request_timeout", r.RemoteAddr, "Request body too slow")
+		writeError(w, http.StatusRequestTimeout, "request_timeout", "Request body took too long to send")
+		return
+	}
+	writeError(w, http.StatusBadRequest, "bad_request", "Request body is not valid JSON")
+}
+
+// messageLimiter fails reads once more than its limit has been read, it's
+// reset before each game request is read.
+type messageLimiter struct {
+	r    io.Reader
+	left int64
+}
+
+func (l *messageLimiter) Read(p []byte) (int, error) {
+	if l.left <= 0 {
+		return 0, errMessageTooLarge
+	}
+	if int64(len(p)) > l.left {
+		p = p[:l.left]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	return n, err
+}
+
+// clientID is who a REST request counts against for MaxRoomsPerClient, the
+// account if it's logged in and otherwise its IP address.
+func clientID(r *http.Request) string {
+	if username := requestUsername(r); username != "" {
+		return "user:" + username
+	}
+	return "ip:" + addressIP(r.RemoteAddr)
+}
+
+// addressIP is the IP address of a host:port address.
+func addressIP(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultShutdownTimeout is how long Shutdown gets when Start's context ends.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// maxAcceptBackoff caps how long the game listener waits after a failed Accept.
+const maxAcceptBackoff = time.Second
+
+// Start listens on TCPAddr and RESTAddr and serves both in the background,
+// along with the janitor and, if SnapshotPath is set, snapshots. TCPAddr and
+// RESTAddr are updated to the addresses actually listened on, so ":0" picks
+// free ports. When ctx ends the server shuts down as if Shutdown was called
+// with ShutdownTimeout, requests get ctx's values but not its cancellation.
+func (s *Server) Start(ctx context.Context) error {
+	tcpListener, err := net.Listen("tcp", s.TCPAddr)
+	if err != nil {
+		return fmt.Errorf("couldn't listen for game connections\n%w", err)
+	}
+	restListener, err := net.Listen("tcp", s.RESTAddr)
+	if err != nil {
+		tcpListener.Close()
+		return fmt.Errorf("couldn't listen for REST requests\n%w", err)
+	}
+
+	s.mu.Lock()
+	s.TCPAddr = tcpListener.Addr().String()
+	s.RESTAddr = restListener.Addr().String()
+	if s.TLSConfig != nil {
+		tcpListener = tls.NewListener(tcpListener, s.TLSConfig)
+		restListener = tls.NewListener(restListener, s.TLSConfig)
+	}
+
+	requestCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s.cancelRequests = cancel
+	s.tcpListener = tcpListener
+	s.httpServer = &http.Server{
+		Handler:           s.limitBodies(s.routes()),
+		ReadHeaderTimeout: s.RESTReadHeaderTimeout,
+		IdleTimeout:       s.RESTIdleTimeout,
+		BaseContext: func(net.Listener) context.Context {
+			return requestCtx
+		},
+	}
+	httpServer := s.httpServer
+	s.mu.Unlock()
+
+	go s.serveTCP(tcpListener)
+	go func() {
+		if err := httpServer.Serve(restListener); !errors.Is(err, http.ErrServerClosed) {
+			log.Err(err).Msg("REST server stopped")
+		}
+	}()
+	go s.StartJanitor()
+	if s.SnapshotPath != "" {
+		go s.StartSnapshots(s.SnapshotPath)
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.closing:
+			return // Shutdown was called directly
+		}
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+		defer cancel()
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			log.Err(err).Msg("Failed to shut down cleanly")
+		}
+	}()
+
+	log.Info().Str("tcp", s.TCPAddr).Str("rest", s.RESTAddr).Bool("tls", s.TLSConfig != nil).Msg("Started server")
+	return nil
+}
+
+// serveTCP accepts game connections until the listener is closed.
+func (s *Server) serveTCP(listener net.Listener) {
+	var backoff time.Duration
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			backoff = min(max(2*backoff, 5*time.Millisecond), maxAcceptBackoff)
+			log.Err(err).Dur("retry", backoff).Msg("Failed to accept connection")
+			time.Sleep(backoff)
+			continue
+		}
+		backoff = 0
+
+		go s.handleConnection(conn)
+	}
+}
+
+// Shutdown stops the server taking new connections, tells everyone connected
+// that it is shutting down, drains REST requests, writes a snapshot if
+// SnapshotPath is set and closes every connection. Seats are left held so a
+// snapshot restores them. It returns once that's done or ctx ends, calling
+// it again waits for the first call. The store is left open, see Close.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() {
+		s.shutdownErr = s.shutdown(ctx)
+		close(s.stopped)
+	})
+	return s.shutdownErr
+}
+
+// Done is closed once the server has shut down.
+func (s *Server) Done() <-chan struct{} {
+	return s.stopped
+}
+
+func (s *Server) shutdown(ctx context.Context) error {
+	log.Info().Msg("Shutting down")
+
+	s.mu.Lock()
+	s.shuttingDown = true
+	close(s.closing)
+	tcpListener, httpServer, cancelRequests := s.tcpListener, s.httpServer, s.cancelRequests
+	for conn := range s.conns {
+		s.sendMessage(conn, ShuttingDown, ShutdownContent{Message: "Server is shutting down"})
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	if tcpListener != nil {
+		if err := tcpListener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, fmt.Errorf("couldn't close game listener\n%w", err))
+		}
+	}
+	if cancelRequests != nil {
+		cancelRequests() // ends long polls and event streams
+	}
+	if httpServer != nil {
+		if err := httpServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("couldn't drain REST requests\n%w", err))
+		}
+	}
+
+	if s.SnapshotPath != "" {
+		if err := s.WriteSnapshot(s.SnapshotPath); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Ending the reads lets each connection flush its queue, the ShuttingDown
+	// notice included, before handleConnection closes it.
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.connections.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("gave up waiting for game connections to close\n%w", ctx.Err()))
+	}
+
+	s.mu.Lock()
+	for _, room := range s.Rooms {
+		room.stopClock()
+		for _, player := range room.players {
+			if player.dropTimer != nil {
+				player.dropTimer.Stop()
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	log.Info().Msg("Shut down")
+	return errors.Join(errs...)
+}
+
+// trackConnection adds conn to the connections Shutdown closes and starts
+// writing its messages, it reports false if the server is already shutting
+// down.
+func (s *Server) trackConnection(conn net.Conn) (*outbox, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shuttingDown {
+		return nil, false
+	}
+	o := &outbox{
+		conn:  conn,
+		queue: make(chan []byte, outboxSize),
+		done:  make(chan struct{}),
+	}
+	s.conns[conn] = o
+	s.connections.Add(1)
+	go s.writeMessages(o)
+	return o, true
+}
+
+// untrackConnection stops messages being queued for conn, its writer stops
+// once the queue is written.
+func (s *Server) untrackConnection(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	close(s.conns[conn].queue)
+	delete(s.conns, conn)
+	delete(s.rtts, conn)
+	s.connections.Done()
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrains(t *testing.T) {
+	s := NewServer()
+	s.TCPAddr, s.RESTAddr = "127.0.0.1:0", "127.0.0.1:0"
+	s.HeartbeatInterval = 0
+	s.SnapshotPath = filepath.Join(t.TempDir(), "snapshot.json")
+	if _, err := s.MakeRoom("r", RoomOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	player := dialGame(t, s)
+	sendRequest(t, player, JoinRoom, RoomContent{Room: "r"})
+	responses := make(chan ResponseType, 16)
+	go func() {
+		defer close(responses)
+		decoder := json.NewDecoder(player)
+		for {
+			var response Response
+			if err := decoder.Decode(&response); err != nil {
+				return
+			}
+			responses <- response.Type
+		}
+	}()
+	eventually(t, time.Second, "the player's seat", func() bool {
+		return withLock(s, func() bool { return len(s.Rooms["r"].players) == 1 })
+	})
+
+	// a long poll that only ends when the server shuts down
+	polled := make(chan error, 1)
+	go func() {
+		res, err := http.Get("http://" + s.RESTAddr + "/rooms/r/state?since=1000&wait=60s")
+		if err == nil {
+			res.Body.Close()
+		}
+		polled <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	cancel()
+	select {
+	case <-s.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("server didn't shut down after Start's context ended")
+	}
+
+	if err := <-polled; err != nil {
+		t.Errorf("long poll wasn't answered before shutdown: %v", err)
+	}
+
+	var got []ResponseType
+	for responseType := range responses {
+		got = append(got, responseType)
+	}
+	if len(got) == 0 || got[len(got)-1] != ShuttingDown {
+		t.Errorf("player got %v, want ShuttingDown last", got)
+	}
+	if _, err := player.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Errorf("player's connection wasn't closed: %v", err)
+	}
+
+	if conn, err := net.DialTimeout("tcp", s.TCPAddr, time.Second); err == nil {
+		conn.Close()
+		t.Error("game listener still accepting after shutdown")
+	}
+	if _, err := http.Get("http://" + s.RESTAddr + "/rooms"); err == nil {
+		t.Error("REST server still answering after shutdown")
+	}
+
+	held := withLock(s, func() bool { return len(s.Rooms["r"].players) == 1 })
+	if !held {
+		t.Error("player's seat was released by shutdown")
+	}
+	if _, err := os.Stat(s.SnapshotPath); err != nil {
+		t.Errorf("no snapshot written: %v", err)
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("second Shutdown: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Variants are the game variants players can queue for.
+var Variants = []string{"standard"}
+
+type queueEntry struct {
+	conn        net.Conn
+	variant     string
+	timeControl TimeControl
+	rating      float64
+	queuedAt    time.Time
+}
+
+func (e *queueEntry) compatible(other *queueEntry) bool {
+	return e.variant == other.variant && e.timeControl == other.timeControl
+}
+
+// handleQuickMatch queues conn and pairs it straight away if a compatible
+// opponent is waiting. It must be called with s.mu held.
+func (s *Server) handleQuickMatch(conn net.Conn, rawContent json.RawMessage) {
+	var content QuickMatchContent
+	if err := json.Unmarshal(rawContent, &content); err != nil {
+		log.Err(err).Msg("Failed to unmarshall QuickMatchContent")
+		s.sendError(conn, "Malformed QuickMatch request")
+		return
+	}
+
+	if content.Variant == "" {
+		content.Variant = Variants[0]
+	}
+	if !slices.Contains(Variants, content.Variant) {
+		s.sendError(conn, "Unknown variant "+content.Variant)
+		return
+	}
+
+	timeControl, err := ParseTimeControl(content.TimeControl)
+	if err != nil {
+		s.sendError(conn, err.Error())
+		return
+	}
+	if content.TimeControl == "" {
+		timeControl = s.DefaultTimeControl
+	}
+
+	s.dequeue(conn)
+	entry := &queueEntry{
+		conn:        conn,
+		variant:     content.Variant,
+		timeControl: timeControl,
+		rating:      s.ratingOf(s.sessions[conn]),
+		queuedAt:    time.Now(),
+	}
+
+	if opponent := s.findOpponent(entry); opponent != nil {
+		s.dequeue(opponent.conn)
+		s.startMatch(opponent, entry)
+		return
+	}
+
+	s.queue = append(s.queue, entry)
+	s.sendMessage(conn, Queued, content)
+
+	log.Info().Str("address", conn.RemoteAddr().String()).Str("variant", entry.variant).Str("timeControl", timeControl.String()).Int("queued", len(s.queue)).Msg("Player queued for quick match")
+}
+
+// findOpponent returns the compatible queued player with the closest rating.
+func (s *Server) findOpponent(entry *queueEntry) *queueEntry {
+	var best *queueEntry
+	for _, other := range s.queue {
+		if !entry.compatible(other) {
+			continue
+		}
+		if best == nil || math.Abs(other.rating-entry.rating) < math.Abs(best.rating-entry.rating) {
+			best = other
+		}
+	}
+	return best
+}
+
+// dequeue removes conn from the matchmaking queue, it reports whether it was queued.
+func (s *Server) dequeue(conn net.Conn) bool {
+	before := len(s.queue)
+	s.queue = slices.DeleteFunc(s.queue, func(e *queueEntry) bool {
+		return e.conn == conn
+	})
+	return len(s.queue) != before
+}
+
+// startMatch puts both players in a new private room, the one who waited
+// longer plays X. If the room can't be made both are told and neither is
+// queued any more.
+func (s *Server) startMatch(first *queueEntry, second *queueEntry) {
+	room, err := s.makeRoom("", RoomOptions{
+		TimeControl: first.timeControl,
+		Private:     true,
+	})
+	if err != nil {
+		code := "no_room_name"
+		if errors.Is(err, ErrTooManyRooms) {
+			code = "too_many_rooms"
+			s.violation(code, second.conn.RemoteAddr().String(), "Room cap reached")
+		}
+		for _, entry := range []*queueEntry{first, second} {
+			s.sendMessage(entry.conn, Error, ErrorContent{
+				Code:    code,
+				Message: "Couldn't start the match: " + err.Error(),
+			})
+		}
+		return
+	}
+
+	s.seatPlayer(room, first.conn, "X")
+	s.seatPlayer(room, second.conn, "O")
+	room.started = true
+	room.startClock(s.flagFunc(room.name))
+
+	s.broadcastUpdates(room.name)
+
+	log.Info().Str("room", room.name).Str("variant", first.variant).Dur("waited", time.Since(first.queuedAt)).Msg("Matched players")
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestQuickMatchRespectsMaxRooms(t *testing.T) {
+	s := NewServer()
+	s.HeartbeatInterval = 0
+	s.MaxRooms = 1
+	startTestServer(t, s)
+	if _, err := s.MakeRoom("full", RoomOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// readError waits for the Error response sent to conn.
+	readError := func(conn net.Conn) ErrorContent {
+		t.Helper()
+
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		decoder := json.NewDecoder(conn)
+		for {
+			var content json.RawMessage
+			response := Response{Content: &content}
+			if err := decoder.Decode(&response); err != nil {
+				t.Fatalf("no error sent: %v", err)
+			}
+			if response.Type != Error {
+				continue
+			}
+
+			var errorContent ErrorContent
+			if err := json.Unmarshal(content, &errorContent); err != nil {
+				t.Fatal(err)
+			}
+			return errorContent
+		}
+	}
+
+	first, second := dialGame(t, s), dialGame(t, s)
+	sendRequest(t, first, QuickMatch, QuickMatchContent{})
+	eventually(t, time.Second, "the first player to queue", func() bool {
+		return withLock(s, func() bool { return len(s.queue) == 1 })
+	})
+	sendRequest(t, second, QuickMatch, QuickMatchContent{})
+
+	for _, conn := range []net.Conn{first, second} {
+		if got := readError(conn); got.Code != "too_many_rooms" {
+			t.Errorf("got error %+v, want too_many_rooms", got)
+		}
+	}
+	rooms, queued := 0, 0
+	withLock(s, func() any {
+		rooms, queued = len(s.Rooms), len(s.queue)
+		return nil
+	})
+	if rooms != 1 {
+		t.Errorf("%d rooms open, want MaxRooms (1)", rooms)
+	}
+	if queued != 0 {
+		t.Errorf("%d players still queued", queued)
+	}
+}
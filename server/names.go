@@ -0,0 +1,56 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+)
+
+const MaxRoomNameLength = 32
+
+// roomNameAttempts is how many short names generateRoomName tries before
+// switching to long ones, and how many long ones it tries before giving up.
+const roomNameAttempts = 20
+
+var (
+	ErrRoomExists   = errors.New("a room with that name already exists")
+	ErrTooManyRooms = errors.New("the server has as many rooms open as it allows")
+	// ErrTooManyClientRooms is returned by MakeRoom when the room's creator
+	// already has MaxRoomsPerClient rooms open.
+	ErrTooManyClientRooms = errors.New("you have as many rooms open as the server allows, close one first")
+	ErrNoRoomName         = errors.New("couldn't find an unused room name, try again")
+	ErrInvalidRoomName    = fmt.Errorf("room names must be 1-%d letters, digits, spaces, '.', '_' or '-' and start with a letter or digit", MaxRoomNameLength)
+)
+
+var roomNamePattern = regexp.MustCompile(fmt.Sprintf(`^[A-Za-z0-9][A-Za-z0-9 ._-]{0,%d}$`, MaxRoomNameLength-1))
+
+var (
+	nameAdjectives = []string{"brave", "calm", "clever", "eager", "fuzzy", "gentle", "happy", "jolly", "lucky", "mighty", "quick", "quiet", "shiny", "sneaky", "swift", "witty"}
+	nameNouns      = []string{"badger", "cactus", "comet", "falcon", "fox", "heron", "koala", "lynx", "otter", "panda", "pebble", "raven", "tiger", "walrus", "wombat", "yak"}
+)
+
+func ValidRoomName(name string) bool {
+	return roomNamePattern.MatchString(name)
+}
+
+// generateRoomName picks an unused name like "swift-otter-42", or one like
+// "swift-otter-5f3a9c1e" when the short names are mostly taken. It must be
+// called with s.mu held.
+func (s *Server) generateRoomName() (string, error) {
+	for attempt := 0; attempt < 2*roomNameAttempts; attempt++ {
+		suffix := fmt.Sprint(rand.IntN(100))
+		if attempt >= roomNameAttempts {
+			suffix = fmt.Sprintf("%08x", rand.Uint32())
+		}
+
+		name := fmt.Sprintf("%s-%s-%s",
+			nameAdjectives[rand.IntN(len(nameAdjectives))],
+			nameNouns[rand.IntN(len(nameNouns))],
+			suffix)
+		if _, ok := s.Rooms[name]; !ok {
+			return name, nil
+		}
+	}
+	return "", ErrNoRoomName
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+func TestGenerateRoomNameWhenShortNamesRunOut(t *testing.T) {
+	s := NewServer()
+	for _, adjective := range nameAdjectives {
+		for _, noun := range nameNouns {
+			for i := 0; i < 100; i++ {
+				s.Rooms[fmt.Sprintf("%s-%s-%d", adjective, noun, i)] = nil
+			}
+		}
+	}
+
+	long := regexp.MustCompile(`^[a-z]+-[a-z]+-[0-9a-f]{8}$`)
+	for i := 0; i < 100; i++ {
+		name, err := s.generateRoomName()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !long.MatchString(name) || !ValidRoomName(name) {
+			t.Fatalf("generated %q", name)
+		}
+		if _, ok := s.Rooms[name]; ok {
+			t.Fatalf("generated %q, which is taken", name)
+		}
+		s.Rooms[name] = nil
+	}
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// openAPI documents the REST API, the rest package is a client for it. Keep
+// the two in step when adding or changing an endpoint.
+//
+//go:embed openapi.json
+var openAPI []byte
+
+func (s *Server) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET /openapi.json request")
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(openAPI); err != nil {
+		log.Err(err).Msg("Failed to write OpenAPI document")
+	}
+}
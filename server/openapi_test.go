@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPI, &spec); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+
+	methods := []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+	documented := map[string]bool{}
+	for path, item := range spec.Paths {
+		for method := range item {
+			if slices.Contains(methods, method) {
+				documented[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+
+	s := NewServer()
+	router := s.routes()
+	for _, route := range s.restRoutes() {
+		if !documented[route.pattern] {
+			t.Errorf("%s is served but not in openapi.json", route.pattern)
+		}
+		delete(documented, route.pattern)
+	}
+	for pattern := range documented {
+		t.Errorf("%s is in openapi.json but not served", pattern)
+	}
+
+	// the table is what's actually served
+	for _, route := range s.restRoutes() {
+		method, path, _ := strings.Cut(route.pattern, " ")
+		if _, pattern := router.Handler(httptest.NewRequest(method, path, nil)); pattern != route.pattern {
+			t.Errorf("%s is routed to %q", route.pattern, pattern)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK || !json.Valid(w.Body.Bytes()) {
+		t.Errorf("GET /openapi.json = %d", w.Code)
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultWriteTimeout is how long a write to a game connection can take
+	// before the connection is dropped.
+	DefaultWriteTimeout = 10 * time.Second
+
+	// outboxSize is how many messages can wait for a game connection before
+	// it's dropped for not reading them.
+	outboxSize = 64
+)
+
+// outbox holds the messages waiting to be written to a game connection. Only
+// its writer goroutine writes to the connection, so a client that stops
+// reading never blocks anyone holding s.mu.
+type outbox struct {
+	conn    net.Conn
+	queue   chan []byte
+	done    chan struct{} // closed when the writer has stopped
+	closing bool          // the connection is already being closed
+}
+
+// writeMessages writes conn's messages until its queue is closed. The first
+// failed write closes the connection, which ends handleConnection's reads and
+// drops the connection, and the rest of the queue is thrown away.
+func (s *Server) writeMessages(o *outbox) {
+	defer close(o.done)
+
+	failed := false
+	for message := range o.queue {
+		if failed {
+			continue
+		}
+
+		if s.WriteTimeout > 0 {
+			o.conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
+		}
+		if _, err := o.conn.Write(message); err != nil {
+			log.Info().Err(err).Str("address", o.conn.RemoteAddr().String()).Msg("Failed to write message, dropping connection")
+			failed = true
+			o.conn.Close()
+		}
+	}
+}
+
+// sendMessage queues a message for conn, it must be called with s.mu held.
+// A connection whose queue is full isn't keeping up and is closed.
+func (s *Server) sendMessage(conn net.Conn, responseType ResponseType, content any) {
+	message, err := json.Marshal(Response{
+		Type:    responseType,
+		Content: content,
+	})
+	if err != nil {
+		log.Err(err).Msg("Failed to encode message")
+		return
+	}
+
+	o, ok := s.conns[conn]
+	if !ok || o.closing {
+		return
+	}
+
+	select {
+	case o.queue <- append(message, '\n'):
+	default:
+		log.Warn().Str("address", conn.RemoteAddr().String()).Msg("Client isn't reading its messages, dropping connection")
+		o.closing = true
+		go conn.Close() // closing can block on a WebSocket or TLS goodbye
+	}
+}
@@ -0,0 +1,349 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultRESTSeatTTL is how long a seat taken over REST is held without
+	// the player making a request with its token.
+	DefaultRESTSeatTTL = 5 * time.Minute
+
+	defaultPollWait = 30 * time.Second
+	maxPollWait     = 60 * time.Second
+)
+
+var (
+	ErrRoomFull    = errors.New("room is full")
+	ErrNotStarted  = errors.New("waiting for an opponent")
+	ErrNotYourTurn = errors.New("it is not your turn")
+	ErrIllegalMove = errors.New("moves are a free cell from 1 to 9")
+	ErrGameOver    = errors.New("the game is over")
+	ErrOutOfTime   = errors.New("you ran out of time")
+	ErrInvalidSeat = errors.New("seat token is not valid for this room")
+	ErrNotYourSeat = errors.New("that seat belongs to another account")
+
+	errBadBody = errors.New("request body is not valid JSON")
+)
+
+// openSeat returns the mark the next player to join room gets, starting the
+// game when it's the second seat. It must be called with s.mu held.
+func (s *Server) openSeat(room *Room) (string, error) {
+	switch len(room.players) {
+	case 0:
+		return "X", nil
+	case 1:
+		mark := "O"
+		for _, player := range room.players {
+			if player.mark == "O" {
+				mark = "X"
+			}
+		}
+		if !room.started {
+			room.started = true
+			room.startClock(s.flagFunc(room.name))
+		}
+		return mark, nil
+	}
+	return "", ErrRoomFull
+}
+
+// addSeat puts player in room.
+func addSeat(room *Room, player *Player) {
+	room.players[player.token] = player
+	room.record(Event{Type: PlayerJoinedEvent, Mark: player.mark, Username: player.username})
+}
+
+// makeMove plays move for player, it must be called with s.mu held. The game
+// is over with ErrOutOfTime if the player's flag fell before they moved.
+func (s *Server) makeMove(room *Room, player *Player, move string) error {
+	if cell, err := strconv.Atoi(move); err != nil || cell < 1 || cell > 9 {
+		return ErrIllegalMove
+	}
+
+	switch {
+	case room.game.HasWinner():
+		return ErrGameOver
+	case len(room.players) < 2:
+		return ErrNotStarted
+	case room.game.Turn != player.mark:
+		return ErrNotYourTurn
+	case !room.chargeClock():
+		s.timeOut(room)
+		return ErrOutOfTime
+	case !room.game.Move(move):
+		if room.game.HasWinner() {
+			return ErrGameOver
+		}
+		return ErrIllegalMove
+	}
+
+	room.record(Event{Type: MoveMadeEvent, Mark: player.mark, Move: move})
+	room.addIncrement(player.mark)
+	room.restartTurn()
+	if room.game.HasWinner() {
+		room.stopClock()
+	}
+	room.drawOffer = ""
+	room.takebackOffer = ""
+	log.Info().Str("move", move).Str("room", room.name).Msg("Made move")
+
+	return nil
+}
+
+// holdRESTSeat keeps a seat used over REST for RESTSeatTTL from now, a player
+// holding it over TCP or WebSocket keeps it for as long as they're connected.
+func (s *Server) holdRESTSeat(room *Room, player *Player) {
+	if player.connected() {
+		return
+	}
+	if player.dropTimer != nil {
+		player.dropTimer.Stop()
+	}
+	player.dropTimer = time.AfterFunc(s.RESTSeatTTL, func() {
+		s.releaseSeat(room.name, player.token)
+	})
+}
+
+// canView reports whether r can see room's game, by the room being public or
+// r having its ?code= or the ?token= of a seat in it. It must be called with
+// s.mu held.
+func canView(r *http.Request, room *Room) bool {
+	query := r.URL.Query()
+	if player, _ := restSeat(r, room, query.Get("token")); player != nil {
+		return true
+	}
+	return room.admits(query.Get("code"), "")
+}
+
+// restSeat finds the seat token belongs to, checking the request's account
+// owns it. It must be called with s.mu held.
+func restSeat(r *http.Request, room *Room, token string) (*Player, error) {
+	player, ok := room.players[token]
+	if !ok || token == "" {
+		return nil, ErrInvalidSeat
+	}
+	if player.username != "" && !strings.EqualFold(player.username, requestUsername(r)) {
+		return nil, ErrNotYourSeat
+	}
+	return player, nil
+}
+
+// decodeContent reads a Request envelope into content, an empty body leaves
+// content untouched.
+func decodeContent(r *http.Request, content any) error {
+	request := Request{
+		Content: content,
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return err
+		}
+		return errBadBody
+	}
+	return nil
+}
+
+func (s *Server) postJoinRoom(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	log.Info().Str("name", name).Msg("POST /rooms/{name}/join request")
+
+	var content RoomContent
+	if err := decodeContent(r, &content); err != nil {
+		s.writeBodyError(w, r, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room := s.getRoom(name)
+	if room == nil {
+		writeError(w, http.StatusNotFound, "room_not_found", "Room does not exist")
+		return
+	}
+	if !room.admits(content.Code, content.Password) {
+		writeError(w, http.StatusForbidden, "room_private", "Room is private, a valid invite code or password is needed")
+		return
+	}
+
+	mark, err := s.openSeat(room)
+	if err != nil {
+		writeError(w, http.StatusConflict, "room_full", err.Error())
+		return
+	}
+
+	player := &Player{
+		mark:     mark,
+		token:    newToken(),
+		username: requestUsername(r),
+		address:  r.RemoteAddr,
+	}
+	addSeat(room, player)
+	s.holdRESTSeat(room, player)
+	s.broadcastUpdates(room.name)
+
+	log.Info().Str("address", r.RemoteAddr).Str("mark", mark).Str("room", room.name).Msg("Player joined room over REST")
+
+	response := Response{
+		Type: AssignMark,
+		Content: AssignMarkContent{
+			Room:   room.name,
+			Player: mark,
+			Token:  player.token,
+		},
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Err(err).Msg("Failed to encode AssignMarkContent")
+	}
+}
+
+func (s *Server) postMove(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	log.Info().Str("name", name).Msg("POST /rooms/{name}/moves request")
+
+	var content MakeMoveContent
+	if err := decodeContent(r, &content); err != nil {
+		s.writeBodyError(w, r, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room := s.getRoom(name)
+	if room == nil {
+		writeError(w, http.StatusNotFound, "room_not_found", "Room does not exist")
+		return
+	}
+
+	player, err := restSeat(r, room, content.Token)
+	if err != nil {
+		writeError(w, http.StatusForbidden, "invalid_seat", err.Error())
+		return
+	}
+	s.holdRESTSeat(room, player)
+
+	err = s.makeMove(room, player, content.Move)
+	switch {
+	case errors.Is(err, ErrIllegalMove):
+		writeError(w, http.StatusBadRequest, "illegal_move", err.Error())
+		return
+	case errors.Is(err, ErrOutOfTime):
+		s.broadcastUpdates(room.name)
+		writeError(w, http.StatusConflict, "out_of_time", err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusConflict, "move_refused", err.Error())
+		return
+	}
+
+	s.broadcastUpdates(room.name)
+	s.writeRoomState(w, room)
+}
+
+// getRoomState returns the room's game. With ?since=VERSION it long-polls,
+// waiting up to ?wait= (30s by default) for the version to move past it.
+// Private rooms need ?code= or the ?token= of a seat in them.
+func (s *Server) getRoomState(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	log.Info().Str("name", name).Msg("GET /rooms/{name}/state request")
+
+	query := r.URL.Query()
+	since, err := queryInt(r, "since", -1)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "since must be a version number")
+		return
+	}
+	wait := defaultPollWait
+	if value := query.Get("wait"); value != "" {
+		if wait, err = time.ParseDuration(value); err != nil || wait < 0 || wait > maxPollWait {
+			writeError(w, http.StatusBadRequest, "bad_request", "wait must be a duration up to "+maxPollWait.String())
+			return
+		}
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	var seen *Room
+	timedOut := false
+	for {
+		s.mu.Lock()
+		room := s.Rooms[name]
+		switch {
+		case room == nil && seen == nil:
+			s.mu.Unlock()
+			writeError(w, http.StatusNotFound, "room_not_found", "Room does not exist")
+			return
+		case room != seen && seen != nil:
+			s.mu.Unlock()
+			writeError(w, http.StatusGone, "room_closed", "Room was closed")
+			return
+		}
+
+		if seen == nil {
+			seen = room
+			player, _ := restSeat(r, room, query.Get("token"))
+			if player == nil && !room.admits(query.Get("code"), "") {
+				s.mu.Unlock()
+				writeError(w, http.StatusForbidden, "room_private", "Room is private, a valid invite code or password is needed")
+				return
+			}
+			if player != nil {
+				s.holdRESTSeat(room, player)
+			}
+		}
+
+		if since < 0 || room.version > since || timedOut {
+			s.writeRoomState(w, room)
+			s.mu.Unlock()
+			return
+		}
+		changed := room.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			timedOut = true
+		case <-r.Context().Done():
+			select {
+			case <-s.closing:
+				writeError(w, http.StatusServiceUnavailable, "shutting_down", "Server is shutting down")
+			default: // the client went away
+			}
+			return
+		}
+	}
+}
+
+// writeRoomState must be called with s.mu held.
+func (s *Server) writeRoomState(w http.ResponseWriter, room *Room) {
+	content := GameStateContent{
+		Room:              room.name,
+		Version:           room.version,
+		UpdateGameContent: room.updateContent(),
+	}
+	content.Ratings = s.roomRatings(room)
+
+	response := Response{
+		Type:    GameState,
+		Content: content,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Err(err).Msg("Failed to encode GameStateContent")
+	}
+}
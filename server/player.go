@@ -1,15 +1,53 @@
 package server
 
-import "net"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"time"
+)
 
 type Player struct {
 	mark       string
+	token      string
+	username   string // empty for guests
+	address    string
 	connection net.Conn
+	dropTimer  *time.Timer
 }
 
-func NewPlayer(mark string, connection net.Conn) Player {
-	return Player{
+func NewPlayer(mark string, connection net.Conn) *Player {
+	return &Player{
 		mark:       mark,
+		token:      newToken(),
+		address:    connection.RemoteAddr().String(),
 		connection: connection,
 	}
 }
+
+type Spectator struct {
+	username   string
+	address    string
+	connection net.Conn
+}
+
+func NewSpectator(connection net.Conn) *Spectator {
+	return &Spectator{
+		address:    connection.RemoteAddr().String(),
+		connection: connection,
+	}
+}
+
+// connected reports whether the player currently has a live connection, a
+// player without one is holding their seat until they resume or time out.
+func (p *Player) connected() bool {
+	return p.connection != nil
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
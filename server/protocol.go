@@ -1,13 +1,41 @@
 package server
 
-import "github.com/tylerolson/tictacgo"
+import (
+	"time"
+
+	"github.com/tylerolson/tictacgo"
+)
 
 type RequestType string
 
 const (
-	MakeRoom RequestType = "MakeRoom"
-	JoinRoom RequestType = "JoinRoom"
-	MakeMove RequestType = "MakeMove"
+	MakeRoom  RequestType = "MakeRoom"
+	JoinRoom  RequestType = "JoinRoom"
+	MakeMove  RequestType = "MakeMove"
+	Resume    RequestType = "Resume"
+	Spectate  RequestType = "Spectate"
+	LeaveRoom RequestType = "LeaveRoom"
+
+	// Authenticate sends a session token, accounts are made and logged in
+	// to over REST.
+	Authenticate RequestType = "Authenticate"
+
+	QuickMatch       RequestType = "QuickMatch"
+	CancelQuickMatch RequestType = "CancelQuickMatch"
+
+	Resign         RequestType = "Resign"
+	OfferDraw      RequestType = "OfferDraw"
+	AcceptDraw     RequestType = "AcceptDraw"
+	DeclineDraw    RequestType = "DeclineDraw"
+	RequestRematch RequestType = "RequestRematch"
+	AcceptRematch  RequestType = "AcceptRematch"
+
+	RequestTakeback RequestType = "RequestTakeback"
+	AcceptTakeback  RequestType = "AcceptTakeback"
+	DeclineTakeback RequestType = "DeclineTakeback"
+
+	// Pong answers a Ping with the same content.
+	Pong RequestType = "Pong"
 )
 
 type Request struct {
@@ -17,20 +45,73 @@ type Request struct {
 
 type RoomContent struct {
 	Room string `json:"room"`
+
+	// only read when making a room
+	Ranked           bool   `json:"ranked,omitempty"`
+	DisableTakebacks bool   `json:"disableTakebacks,omitempty"`
+	TimeControl      string `json:"timeControl,omitempty"`
+	Private          bool   `json:"private,omitempty"`
+
+	// Password sets a private room's password when making it, and is checked
+	// along with Code when joining one.
+	Password string `json:"password,omitempty"`
+	Code     string `json:"code,omitempty"`
+}
+
+type CredentialsContent struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type AuthenticateContent struct {
+	Token string `json:"token"`
+}
+
+type QuickMatchContent struct {
+	Variant     string `json:"variant"`
+	TimeControl string `json:"timeControl"`
+}
+
+type ResumeContent struct {
+	Room  string `json:"room"`
+	Token string `json:"token"`
 }
 
 type MakeMoveContent struct {
 	Room   string `json:"room"`
 	Move   string `json:"move"`
 	Player string `json:"player"`
+	Token  string `json:"token,omitempty"` // seat token, only read over REST
 }
 
 type ResponseType string
 
 const (
-	GetRoom    ResponseType = "GetRoom"
-	AssignMark ResponseType = "AssignMark"
-	UpdateGame ResponseType = "UpdateGame"
+	GetRoom       ResponseType = "GetRoom"
+	GetSpectators ResponseType = "GetSpectators"
+	CreatedRoom   ResponseType = "CreatedRoom"
+	AssignMark    ResponseType = "AssignMark"
+	Spectating    ResponseType = "Spectating"
+	Queued        ResponseType = "Queued"
+	LoggedIn      ResponseType = "LoggedIn"
+	Authenticated ResponseType = "Authenticated"
+	Leaderboard   ResponseType = "Leaderboard"
+	RatingHistory ResponseType = "RatingHistory"
+	RoomLog       ResponseType = "RoomLog"
+	GameList      ResponseType = "GameList"
+	GameDetail    ResponseType = "GameDetail"
+	GameState     ResponseType = "GameState"
+	ServerInfo    ResponseType = "ServerInfo"
+	Metrics       ResponseType = "Metrics"
+	ShuttingDown  ResponseType = "ShuttingDown"
+	Ping          ResponseType = "Ping"
+	UpdateGame    ResponseType = "UpdateGame"
+	RoomClosed    ResponseType = "RoomClosed"
+	Error         ResponseType = "Error"
+
+	// Reconnecting is never sent by the server, Client emits it on its update
+	// channel while it is trying to resume a dropped session.
+	Reconnecting ResponseType = "Reconnecting"
 )
 
 type Response struct {
@@ -39,16 +120,136 @@ type Response struct {
 }
 
 type RoomResponse struct {
-	Name string `json:"name"`
-	Size int    `json:"size"`
+	Name        string `json:"name"`
+	Size        int    `json:"size"`
+	Spectators  int    `json:"spectators"`
+	TimeControl string `json:"timeControl,omitempty"`
+}
+
+type CreatedRoomResponse struct {
+	Name       string `json:"name"`
+	InviteCode string `json:"inviteCode,omitempty"`
+}
+
+type SpectatorResponse struct {
+	Address  string `json:"address"`
+	Username string `json:"username,omitempty"`
+}
+
+type LeaderboardContent struct {
+	Page     int                `json:"page"`
+	PageSize int                `json:"pageSize"`
+	Total    int                `json:"total"`
+	Entries  []LeaderboardEntry `json:"entries"`
+}
+
+type LeaderboardEntry struct {
+	Rank      int     `json:"rank"`
+	Username  string  `json:"username"`
+	Rating    float64 `json:"rating"`
+	Deviation float64 `json:"deviation"`
+	Games     int     `json:"games"`
+}
+
+type GameListContent struct {
+	Page     int           `json:"page"`
+	PageSize int           `json:"pageSize"`
+	Total    int           `json:"total"`
+	Games    []GameSummary `json:"games"`
+}
+
+type GameSummary struct {
+	ID          string            `json:"id"`
+	Room        string            `json:"room"`
+	Variant     string            `json:"variant"`
+	TimeControl string            `json:"timeControl,omitempty"`
+	Players     map[string]string `json:"players,omitempty"`
+	Result      string            `json:"result"` // 1-0, 0-1 or 1/2-1/2
+	Reason      string            `json:"reason,omitempty"`
+	Plies       int               `json:"plies"`
+	Finished    time.Time         `json:"finished"`
+}
+
+type GameDetailContent struct {
+	GameSummary
+	Moves    []string `json:"moves"`    // every move in coordinate notation, see tictacgo.CellName
+	Notation string   `json:"notation"` // the whole game record with its tags
+}
+
+type SessionContent struct {
+	Username string    `json:"username"`
+	Token    string    `json:"token"`
+	Expires  time.Time `json:"expires"`
 }
 
 type AssignMarkContent struct {
 	Room   string `json:"room"`
 	Player string `json:"player"`
+	Token  string `json:"token"`
 }
 
 type UpdateGameContent struct {
-	Game    tictacgo.Game `json:"game"`
-	Started bool          `json:"started"`
+	Game          tictacgo.Game            `json:"game"`
+	Started       bool                     `json:"started"`
+	DrawOffer     string                   `json:"drawOffer"`     // mark of the player offering a draw
+	RematchOffer  string                   `json:"rematchOffer"`  // mark of the player asking for a rematch
+	TakebackOffer string                   `json:"takebackOffer"` // mark of the player asking to undo their move
+	Takebacks     bool                     `json:"takebacks"`     // whether the room allows takebacks
+	Ranked        bool                     `json:"ranked"`
+	TimeControl   string                   `json:"timeControl,omitempty"`
+	Clocks        map[string]time.Duration `json:"clocks,omitempty"`  // time left per mark when the room has a clock
+	Players       map[string]string        `json:"players,omitempty"` // username per mark for logged in players
+	Ratings       map[string]int           `json:"ratings,omitempty"` // rounded rating per mark for logged in players
+}
+
+// GameStateContent is a room's game as read over REST, Version goes up with
+// every change so pollers can wait for the next one.
+type GameStateContent struct {
+	Room    string `json:"room"`
+	Version int    `json:"version"`
+	UpdateGameContent
+}
+
+// ServerInfoContent tells a client that only knows the REST server's URL where
+// the game server is.
+type ServerInfoContent struct {
+	TCPPort   int    `json:"tcpPort"`   // on the same host as the REST server
+	WebSocket string `json:"webSocket"` // path of the WebSocket endpoint
+	TLS       bool   `json:"tls"`       // whether both servers use TLS
+}
+
+type MetricsContent struct {
+	Rooms       int `json:"rooms"`
+	Connections int `json:"connections"` // open game connections
+	// Violations counts requests refused by a limit, by their error code.
+	Violations map[string]int64 `json:"violations"`
+}
+
+// ShutdownContent is sent to every connection when the server starts shutting
+// down, players can resume their seats if it comes back with a snapshot.
+type ShutdownContent struct {
+	Message string `json:"message"`
+}
+
+// HeartbeatContent is sent in a Ping every Interval and echoed back in the
+// Pong. A connection is dropped after MaxMissed Intervals with nothing from it.
+type HeartbeatContent struct {
+	Sent      time.Time     `json:"sent"`
+	Interval  time.Duration `json:"interval"`
+	MaxMissed int           `json:"maxMissed"`
+	RTT       time.Duration `json:"rtt,omitempty"` // the last Ping's round trip, as the server measured it
+}
+
+type RoomClosedContent struct {
+	Room   string `json:"room"`
+	Reason string `json:"reason"`
+}
+
+type ErrorContent struct {
+	Code    string `json:"code,omitempty"` // machine readable, set on REST errors and refused game requests
+	Message string `json:"message"`
+
+	// RetryAfter is how many seconds to wait after a rate_limited game
+	// request, REST sends a Retry-After header instead.
+	RetryAfter float64 `json:"retryAfter,omitempty"`
 }
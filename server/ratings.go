@@ -0,0 +1,255 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tylerolson/tictacgo/rating"
+	"github.com/tylerolson/tictacgo/store"
+)
+
+const (
+	DefaultRatingSystem = "glicko2"
+
+	defaultLeaderboardPageSize = 20
+	maxLeaderboardPageSize     = 100
+)
+
+// RatingChange is one entry in an account's rating history.
+type RatingChange = store.RatingChange
+
+// ratingSystem returns the configured rating system, falling back to Elo.
+func (s *Server) ratingSystem() rating.System {
+	if system, ok := rating.Systems[s.RatingSystem]; ok {
+		return system
+	}
+	return rating.Elo
+}
+
+// unsavedAccount is an account with rating updates queued but not yet
+// written, writes counts them.
+type unsavedAccount struct {
+	account Account
+	writes  int
+}
+
+// account returns username's account, counting rating updates still waiting
+// to be written. It must be called with s.mu held.
+func (s *Server) account(username string) (Account, error) {
+	if unsaved, ok := s.unsaved[strings.ToLower(username)]; ok {
+		return unsaved.account, nil
+	}
+	return s.store.GetUser(username)
+}
+
+// saveAccount queues account to be written, it must be called with s.mu held.
+func (s *Server) saveAccount(account Account) {
+	key := strings.ToLower(account.Username)
+	s.unsaved[key] = unsavedAccount{account: account, writes: s.unsaved[key].writes + 1}
+	s.queueWrite(func() {
+		if err := s.store.UpdateUser(account); err != nil {
+			log.Error().Err(err).Str("username", account.Username).Msg("Failed to save rating")
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if unsaved := s.unsaved[key]; unsaved.writes > 1 {
+			unsaved.writes--
+			s.unsaved[key] = unsaved
+		} else {
+			delete(s.unsaved, key)
+		}
+	})
+}
+
+// rateGame updates both players' ratings if room's finished game was ranked
+// and both players are logged in. It must be called with s.mu held.
+func (s *Server) rateGame(room *Room) {
+	if !room.options.Ranked {
+		return
+	}
+
+	var x, o *Account
+	for _, player := range room.players {
+		if player.username == "" {
+			continue
+		}
+		account, err := s.account(player.username)
+		if err != nil {
+			log.Error().Err(err).Str("username", player.username).Msg("Failed to load account for rating")
+			continue
+		}
+		if player.mark == "X" {
+			x = &account
+		} else {
+			o = &account
+		}
+	}
+	if x == nil || o == nil || strings.EqualFold(x.Username, o.Username) {
+		log.Info().Str("room", room.name).Msg("Skipped rating, both players need accounts")
+		return
+	}
+
+	scoreX := 0.5
+	switch room.game.Winner {
+	case "X":
+		scoreX = 1
+	case "O":
+		scoreX = 0
+	}
+
+	system := s.ratingSystem()
+	newX := system(x.Rating, o.Rating, scoreX)
+	newO := system(o.Rating, x.Rating, 1-scoreX)
+
+	now := time.Now()
+	x.History = append(x.History, RatingChange{
+		Room:     room.name,
+		Opponent: o.Username,
+		Score:    scoreX,
+		Before:   x.Rating.Rating,
+		After:    newX.Rating,
+		Time:     now,
+	})
+	o.History = append(o.History, RatingChange{
+		Room:     room.name,
+		Opponent: x.Username,
+		Score:    1 - scoreX,
+		Before:   o.Rating.Rating,
+		After:    newO.Rating,
+		Time:     now,
+	})
+	x.Rating, o.Rating = newX, newO
+	x.Games++
+	o.Games++
+
+	s.saveAccount(*x)
+	s.saveAccount(*o)
+
+	log.Info().
+		Str("room", room.name).
+		Str("x", x.Username).Float64("xRating", newX.Rating).
+		Str("o", o.Username).Float64("oRating", newO.Rating).
+		Msg("Rated game")
+}
+
+// ratingOf returns username's rating, or the default for guests. It must be
+// called with s.mu held.
+func (s *Server) ratingOf(username string) float64 {
+	if account, err := s.account(username); err == nil {
+		return account.Rating.Rating
+	}
+	return rating.DefaultRating
+}
+
+// roomRatings maps each mark to the rounded rating of the logged in player
+// holding it. It must be called with s.mu held.
+func (s *Server) roomRatings(room *Room) map[string]int {
+	ratings := make(map[string]int)
+	for _, player := range room.players {
+		if player.username != "" {
+			ratings[player.mark] = int(math.Round(s.ratingOf(player.username)))
+		}
+	}
+	return ratings
+}
+
+func (s *Server) getLeaderboard(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET /leaderboard request")
+
+	page, err := queryInt(r, "page", 1)
+	if err != nil || page < 1 {
+		writeError(w, http.StatusBadRequest, "bad_request", "page must be a positive number")
+		return
+	}
+	pageSize, err := queryInt(r, "pageSize", defaultLeaderboardPageSize)
+	if err != nil || pageSize < 1 || pageSize > maxLeaderboardPageSize {
+		writeError(w, http.StatusBadRequest, "bad_request", "pageSize must be between 1 and "+strconv.Itoa(maxLeaderboardPageSize))
+		return
+	}
+
+	accounts, err := s.store.ListUsers()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list accounts")
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to load leaderboard")
+		return
+	}
+
+	ranked := slices.DeleteFunc(accounts, func(account Account) bool {
+		return account.Games == 0
+	})
+	slices.SortFunc(ranked, func(a, b Account) int {
+		return cmp.Or(cmp.Compare(b.Rating.Rating, a.Rating.Rating), cmp.Compare(a.Username, b.Username))
+	})
+
+	content := LeaderboardContent{
+		Page:     page,
+		PageSize: pageSize,
+		Total:    len(ranked),
+		Entries:  make([]LeaderboardEntry, 0, pageSize),
+	}
+	for i := (page - 1) * pageSize; i < len(ranked) && i < page*pageSize; i++ {
+		content.Entries = append(content.Entries, LeaderboardEntry{
+			Rank:      i + 1,
+			Username:  ranked[i].Username,
+			Rating:    ranked[i].Rating.Rating,
+			Deviation: ranked[i].Rating.Deviation,
+			Games:     ranked[i].Games,
+		})
+	}
+
+	response := Response{
+		Type:    Leaderboard,
+		Content: content,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to encode response")
+	}
+}
+
+func (s *Server) getRatingHistory(w http.ResponseWriter, r *http.Request) {
+	username := r.PathValue("username")
+	log.Info().Str("username", username).Msg("GET /accounts/{username}/ratings request")
+
+	account, err := s.store.GetUser(username)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "account_not_found", "Account does not exist")
+		return
+	} else if err != nil {
+		log.Error().Err(err).Str("username", username).Msg("Failed to load account")
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to load rating history")
+		return
+	}
+
+	history := account.History
+	if history == nil {
+		history = []RatingChange{}
+	}
+
+	response := Response{
+		Type:    RatingHistory,
+		Content: history,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to encode response")
+	}
+}
+
+// queryInt reads an integer query parameter, returning fallback if it's missing.
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -1,24 +1,197 @@
 package server
 
 import (
+	"crypto/rand"
+	"net"
+	"time"
+
 	"github.com/rs/zerolog/log"
 	"github.com/tylerolson/tictacgo"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// inviteAlphabet leaves out characters that are easy to misread.
+const inviteAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+type RoomOptions struct {
+	Ranked           bool
+	DisableTakebacks bool
+	TimeControl      TimeControl
+
+	// Private rooms are left out of GET /rooms and can only be joined with
+	// their invite code or, if PasswordHash is set, the password.
+	Private      bool
+	PasswordHash []byte
+
+	// Creator is who made the room, counted against MaxRoomsPerClient, see
+	// clientID. Rooms made by an account can be closed by it. It's empty for
+	// rooms the server made.
+	Creator string
+	// Permanent rooms, like the ones seeded from config, are never closed by
+	// the janitor.
+	Permanent bool
+}
+
 type Room struct {
-	name    string
-	game    *tictacgo.Game
-	started bool
-	players map[string]Player
+	name          string
+	options       RoomOptions
+	game          *tictacgo.Game
+	started       bool
+	drawOffer     string
+	rematchOffer  string
+	takebackOffer string
+	inviteCode    string
+	players       map[string]*Player // keyed by session token
+	spectators    map[net.Conn]*Spectator
+	events        []Event
+	version       int           // bumped every time an update is broadcast
+	changed       chan struct{} // closed and replaced when version changes
+
+	lastActive time.Time
+	finishedAt time.Time
+	archived   bool
+
+	clocks       map[string]time.Duration
+	clockRunning bool
+	turnStarted  time.Time
+	flagTimer    *time.Timer
+	onFlag       func()
+}
+
+func NewRoom(name string, options RoomOptions) *Room {
+	inviteCode := ""
+	if options.Private {
+		inviteCode = newInviteCode()
+	}
+
+	room := &Room{
+		name:       name,
+		inviteCode: inviteCode,
+		options:    options,
+		game:       tictacgo.NewGame(),
+		started:    false,
+		players:    make(map[string]*Player),
+		spectators: make(map[net.Conn]*Spectator),
+		lastActive: time.Now(),
+		changed:    make(chan struct{}),
+	}
+	room.record(Event{
+		Type:             RoomCreatedEvent,
+		Ranked:           options.Ranked,
+		DisableTakebacks: options.DisableTakebacks,
+		TimeControl:      options.TimeControl.String(),
+		Private:          options.Private,
+	})
+	return room
+}
+
+func newInviteCode() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	for i := range b {
+		b[i] = inviteAlphabet[int(b[i])%len(inviteAlphabet)]
+	}
+	return string(b)
+}
+
+// admits reports whether someone with the given invite code or password may
+// enter the room.
+func (r *Room) admits(code string, password string) bool {
+	if !r.options.Private {
+		return true
+	}
+	if code != "" && code == r.inviteCode {
+		return true
+	}
+	if len(r.options.PasswordHash) > 0 && password != "" {
+		return bcrypt.CompareHashAndPassword(r.options.PasswordHash, []byte(password)) == nil
+	}
+	return false
+}
+
+// touch marks the room as active and notes when its game finished, it
+// returns true the first time it sees the game finished.
+func (r *Room) touch() bool {
+	r.lastActive = time.Now()
+	if r.game.HasWinner() && r.finishedAt.IsZero() {
+		r.finishedAt = r.lastActive
+		return true
+	}
+	return false
+}
+
+func (r *Room) empty() bool {
+	return len(r.players) == 0 && len(r.spectators) == 0
+}
+
+func (r *Room) response() RoomResponse {
+	return RoomResponse{
+		Name:        r.name,
+		Size:        len(r.players),
+		Spectators:  len(r.spectators),
+		TimeControl: r.options.TimeControl.String(),
+	}
+}
+
+func (r *Room) updateContent() UpdateGameContent {
+	return UpdateGameContent{
+		Game:          *r.game,
+		Started:       r.started,
+		DrawOffer:     r.drawOffer,
+		RematchOffer:  r.rematchOffer,
+		TakebackOffer: r.takebackOffer,
+		Takebacks:     r.takebacksAllowed(),
+		Ranked:        r.options.Ranked,
+		TimeControl:   r.options.TimeControl.String(),
+		Clocks:        r.clockContent(),
+		Players:       r.usernames(),
+	}
+}
+
+// usernames maps each mark to the username of the logged in player holding it.
+func (r *Room) usernames() map[string]string {
+	usernames := make(map[string]string)
+	for _, player := range r.players {
+		if player.username != "" {
+			usernames[player.mark] = player.username
+		}
+	}
+	return usernames
+}
+
+func (r *Room) takebacksAllowed() bool {
+	return !r.options.DisableTakebacks
+}
+
+// rematch swaps every player's mark and starts a fresh game.
+func (r *Room) rematch() {
+	for _, player := range r.players {
+		player.mark = tictacgo.Opponent(player.mark)
+	}
+	r.stopClock()
+	r.game = tictacgo.NewGame()
+	r.record(Event{Type: RematchStartedEvent})
+	r.finishedAt = time.Time{}
+	r.archived = false
+	r.clearOffers()
+}
+
+// clearOffers withdraws every open draw, rematch and takeback offer.
+func (r *Room) clearOffers() {
+	r.drawOffer = ""
+	r.rematchOffer = ""
+	r.takebackOffer = ""
 }
 
-func NewRoom(name string) *Room {
-	return &Room{
-		name:    name,
-		game:    tictacgo.NewGame(),
-		started: false,
-		players: make(map[string]Player),
+func (r *Room) playerByConn(conn net.Conn) *Player {
+	for _, player := range r.players {
+		if player.connection == conn {
+			return player
+		}
 	}
+	return nil
 }
 
 func (r Room) PrintRoom() {
@@ -26,5 +199,6 @@ func (r Room) PrintRoom() {
 		Str("Name", r.name).
 		Interface("game", r.game).
 		Interface("Players", r.players).
+		Int("Spectators", len(r.spectators)).
 		Msg("Printed room")
 }
@@ -1,41 +1,342 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"expvar"
+	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/http"
+	"slices"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/tylerolson/tictacgo/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultSeatGracePeriod is how long a dropped player's seat is held for them
+// to resume their session before it is given up.
+const DefaultSeatGracePeriod = 30 * time.Second
+
+const (
+	DefaultTCPAddr  = ":8080"
+	DefaultRESTAddr = ":8081"
 )
 
 type Server struct {
-	Rooms       map[string]*Room
+	// TCPAddr and RESTAddr are where Start listens, both are served over TLS
+	// when TLSConfig is set.
+	TCPAddr   string
+	RESTAddr  string
+	TLSConfig *tls.Config
+
+	Rooms           map[string]*Room
+	SeatGracePeriod time.Duration
+	RoomIdleTTL     time.Duration
+	FinishedRoomTTL time.Duration
+	JanitorInterval time.Duration
+	RESTSeatTTL     time.Duration
+
+	// MaxRoomsPerClient is how many rooms with the same RoomOptions.Creator
+	// can be open at once, 0 means no cap.
+	MaxRoomsPerClient int
+	// MaxRooms is how many rooms can be open before MakeRoom refuses to make
+	// more, 0 means no cap.
+	MaxRooms int
+	// DefaultTimeControl is used for rooms and quick matches that don't ask
+	// for a time control.
+	DefaultTimeControl TimeControl
+
+	// SnapshotInterval is how often StartSnapshots writes the live state.
+	SnapshotInterval time.Duration
+	// SnapshotPath is where Start keeps snapshots and Shutdown writes a last
+	// one, no snapshots are taken if it's empty.
+	SnapshotPath string
+
+	// ShutdownTimeout is how long Shutdown gets when Start's context ends.
+	ShutdownTimeout time.Duration
+
+	// WriteTimeout is how long a write to a game connection can take before
+	// the connection is dropped, 0 means no timeout.
+	WriteTimeout time.Duration
+
+	// Game connections are sent a Ping every HeartbeatInterval and dropped,
+	// holding their seats, if nothing comes back for MaxMissedHeartbeats
+	// intervals. A HeartbeatInterval of 0 turns heartbeats off.
+	HeartbeatInterval   time.Duration
+	MaxMissedHeartbeats int
+
+	// ConnectionRateLimit and IPRateLimit limit how often a single connection
+	// and everything from one IP address can make rooms, join rooms and make
+	// moves, over TCP and REST.
+	ConnectionRateLimit RateLimit
+	IPRateLimit         RateLimit
+	// MaxMessageSize is the largest game request or REST body in bytes, 0
+	// means no cap. Game connections that send more are dropped.
+	MaxMessageSize int64
+
+	// REST clients get RESTReadHeaderTimeout to send a request's headers and
+	// RESTBodyTimeout to send its body, and idle connections are closed after
+	// RESTIdleTimeout. 0 means no timeout.
+	RESTReadHeaderTimeout time.Duration
+	RESTBodyTimeout       time.Duration
+	RESTIdleTimeout       time.Duration
+
+	// WebSocket clients on /ws are pinged every WebSocketPingInterval and
+	// dropped if they don't answer within WebSocketPingTimeout.
+	WebSocketPingInterval time.Duration
+	WebSocketPingTimeout  time.Duration
+	MaxWebSocketMessage   int64
+	// WebSocketOrigins are host patterns browsers on other origins may connect
+	// from, the server's own origin is always allowed.
+	WebSocketOrigins []string
+
+	// RequireAuth refuses game requests from connections that haven't sent a
+	// valid session token, and REST requests without a bearer token.
+	RequireAuth bool
+	TokenSecret []byte
+	TokenTTL    time.Duration
+
+	// Admins are the usernames that can close any room with
+	// DELETE /rooms/{name}.
+	Admins []string
+
+	// RatingSystem names one of rating.Systems, Elo is used if it isn't found.
+	RatingSystem string
+
+	mu          sync.Mutex
+	store       store.Store
+	sessions    map[net.Conn]string        // username of each authenticated connection
+	rtts        map[net.Conn]time.Duration // last heartbeat round trip of each connection
+	buckets     map[bucketKey]*bucket
+	violations  expvar.Map // requests refused by a limit, by error code
+	lobbySubs   subscribers
+	roomSubs    map[string]subscribers
+	queue       []*queueEntry
 	restRouter  *http.ServeMux
 	tcpListener net.Listener
+	httpServer  *http.Server
+
+	writes  []func()                  // store writes waiting for runWrites
+	writing bool                      // runWrites is running
+	unsaved map[string]unsavedAccount // rated accounts still being written, by lowercased username
+
+	conns          map[net.Conn]*outbox // every open game connection
+	connections    sync.WaitGroup       // handleConnection calls still running
+	cancelRequests context.CancelFunc
+	shuttingDown   bool
+	closing        chan struct{} // closed when Shutdown starts
+	stopped        chan struct{} // closed when Shutdown is done
+	shutdownOnce   sync.Once
+	shutdownErr    error
 }
 
+// NewServer returns a server that keeps everything in memory.
 func NewServer() *Server {
-	return &Server{
-		Rooms: make(map[string]*Room),
+	s, err := NewServerWithStore(store.NewMemory())
+	if err != nil {
+		panic(err) // the memory store starts out empty
+	}
+	return s
+}
+
+// NewServerWithStore returns a server backed by st, reopening any rooms that
+// were open when st was last used.
+func NewServerWithStore(st store.Store) (*Server, error) {
+	s := &Server{
+		TCPAddr:               DefaultTCPAddr,
+		RESTAddr:              DefaultRESTAddr,
+		Rooms:                 make(map[string]*Room),
+		SeatGracePeriod:       DefaultSeatGracePeriod,
+		RoomIdleTTL:           DefaultRoomIdleTTL,
+		FinishedRoomTTL:       DefaultFinishedRoomTTL,
+		JanitorInterval:       DefaultJanitorInterval,
+		RESTSeatTTL:           DefaultRESTSeatTTL,
+		SnapshotInterval:      DefaultSnapshotInterval,
+		ShutdownTimeout:       DefaultShutdownTimeout,
+		MaxRoomsPerClient:     DefaultMaxRoomsPerClient,
+		ConnectionRateLimit:   DefaultConnectionRateLimit,
+		IPRateLimit:           DefaultIPRateLimit,
+		MaxMessageSize:        DefaultMaxMessageSize,
+		RESTReadHeaderTimeout: DefaultRESTReadHeaderTimeout,
+		RESTBodyTimeout:       DefaultRESTBodyTimeout,
+		RESTIdleTimeout:       DefaultRESTIdleTimeout,
+		WriteTimeout:          DefaultWriteTimeout,
+		HeartbeatInterval:     DefaultHeartbeatInterval,
+		MaxMissedHeartbeats:   DefaultMaxMissedHeartbeats,
+		WebSocketPingInterval: DefaultWebSocketPingInterval,
+		WebSocketPingTimeout:  DefaultWebSocketPingTimeout,
+		MaxWebSocketMessage:   DefaultMaxWebSocketMessage,
+		TokenSecret:           newTokenSecret(),
+		TokenTTL:              DefaultTokenTTL,
+		RatingSystem:          DefaultRatingSystem,
+		store:                 st,
+		sessions:              make(map[net.Conn]string),
+		rtts:                  make(map[net.Conn]time.Duration),
+		buckets:               make(map[bucketKey]*bucket),
+		lobbySubs:             make(subscribers),
+		roomSubs:              make(map[string]subscribers),
+		unsaved:               make(map[string]unsavedAccount),
+		conns:                 make(map[net.Conn]*outbox),
+		closing:               make(chan struct{}),
+		stopped:               make(chan struct{}),
 	}
+
+	if err := s.restoreRooms(); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
-func (s *Server) removePlayerFromAll(addr string) {
+
+// dropConnection holds every seat belonging to conn for SeatGracePeriod, the
+// seat is released if the player hasn't resumed by then.
+func (s *Server) dropConnection(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shuttingDown {
+		return // seats stay held for the snapshot
+	}
+
+	s.dequeue(conn)
+	delete(s.sessions, conn)
 	for _, room := range s.Rooms {
-		delete(room.players, addr)
+		if _, ok := room.spectators[conn]; ok {
+			delete(room.spectators, conn)
+			s.publishLobby(RoomUpdatedSSE, room)
+			log.Info().Str("room", room.name).Msg("Spectator left")
+		}
+
+		player := room.playerByConn(conn)
+		if player == nil {
+			continue
+		}
+
+		player.connection = nil
+		player.dropTimer = time.AfterFunc(s.SeatGracePeriod, func() {
+			s.releaseSeat(room.name, player.token)
+		})
+
+		log.Info().Str("room", room.name).Str("mark", player.mark).Dur("grace", s.SeatGracePeriod).Msg("Holding seat")
 	}
 }
 
-func (s *Server) MakeRoom(name string) {
-	s.Rooms[name] = NewRoom(name)
+func (s *Server) releaseSeat(roomName string, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	log.Info().Str("name", name).Msg("Created room")
+	room, ok := s.Rooms[roomName]
+	if !ok {
+		return
+	}
+
+	player, ok := room.players[token]
+	if !ok || player.connected() {
+		return
+	}
+
+	delete(room.players, token)
+	room.clearOffers()
+	room.record(Event{Type: PlayerLeftEvent, Mark: player.mark, Username: player.username, Reason: "seat released"})
+	log.Info().Str("event", "seat_released").Str("room", roomName).Str("mark", player.mark).Msg("Released seat")
+	s.broadcastUpdates(roomName)
+}
+
+// MakeRoom creates a room, generating a name if name is empty. It fails with
+// ErrInvalidRoomName or ErrRoomExists rather than replace a room.
+func (s *Server) MakeRoom(name string, options RoomOptions) (*Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.makeRoom(name, options)
+}
+
+// makeRoom is MakeRoom for callers holding s.mu.
+func (s *Server) makeRoom(name string, options RoomOptions) (*Room, error) {
+	if name == "" {
+		var err error
+		if name, err = s.generateRoomName(); err != nil {
+			return nil, err
+		}
+	}
+	if !ValidRoomName(name) {
+		return nil, ErrInvalidRoomName
+	}
+	if _, ok := s.Rooms[name]; ok {
+		return nil, ErrRoomExists
+	}
+	if s.MaxRooms > 0 && len(s.Rooms) >= s.MaxRooms {
+		return nil, ErrTooManyRooms
+	}
+	if options.Creator != "" && s.MaxRoomsPerClient > 0 && s.roomsBy(options.Creator) >= s.MaxRoomsPerClient {
+		return nil, ErrTooManyClientRooms
+	}
+
+	room := NewRoom(name, options)
+	s.Rooms[name] = room
+	s.saveRoom(room)
+	s.publishLobby(RoomCreatedSSE, room)
+
+	log.Info().Str("name", name).Bool("private", options.Private).Msg("Created room")
+	return room, nil
+}
+
+// roomsBy counts the open rooms creator made, it must be called with s.mu held.
+func (s *Server) roomsBy(creator string) int {
+	count := 0
+	for _, room := range s.Rooms {
+		if room.options.Creator == creator {
+			count++
+		}
+	}
+	return count
 }
 
 func (s *Server) GetRoom(name string) *Room {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.getRoom(name)
+}
+
+// enterRoom finds the room a join or spectate request is for, by name or by
+// invite code, and checks the request may enter it. It replies with an error
+// and returns nil otherwise.
+func (s *Server) enterRoom(conn net.Conn, content RoomContent) *Room {
+	var room *Room
+	if content.Room == "" && content.Code != "" {
+		for _, r := range s.Rooms {
+			if r.inviteCode == content.Code {
+				room = r
+				break
+			}
+		}
+	} else {
+		room = s.getRoom(content.Room)
+	}
+
+	if room == nil {
+		s.sendError(conn, "Room does not exist")
+		return nil
+	}
+
+	if !room.admits(content.Code, content.Password) {
+		log.Info().Str("room", room.name).Str("address", conn.RemoteAddr().String()).Msg("Refused entry to private room")
+		s.sendError(conn, "Room is private, a valid invite code or password is needed")
+		return nil
+	}
+
+	return room
+}
+
+func (s *Server) getRoom(name string) *Room {
 	room, ok := s.Rooms[name]
 	if !ok {
 		log.Warn().Str("name", name).Msg("Room does not exist")
@@ -46,16 +347,36 @@ func (s *Server) GetRoom(name string) *Room {
 
 // rest
 
+// writeError replies with a structured Error response.
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	response := Response{
+		Type: Error,
+		Content: ErrorContent{
+			Code:    code,
+			Message: message,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Err(err).Msg("Failed to encode error response")
+	}
+}
+
 func (s *Server) getRooms(w http.ResponseWriter, r *http.Request) {
 	log.Info().Msg("GET /rooms request")
 	rooms := make([]RoomResponse, 0)
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	for _, v := range s.Rooms {
-		room := RoomResponse{
-			Name: v.name,
-			Size: len(v.players),
+		if v.options.Private {
+			continue
 		}
-		rooms = append(rooms, room)
+		rooms = append(rooms, v.response())
 	}
 
 	response := Response{
@@ -64,7 +385,45 @@ func (s *Server) getRooms(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to encode response")
+		return
+	}
+}
+
+// getSpectators lists who is watching the room. Private rooms need ?code= or
+// the ?token= of a seat in them.
+func (s *Server) getSpectators(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	log.Info().Str("name", name).Msg("GET /rooms/{name}/spectators request")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room := s.getRoom(name)
+	if room == nil {
+		writeError(w, http.StatusNotFound, "room_not_found", "Room does not exist")
+		return
+	}
+	if !canView(r, room) {
+		writeError(w, http.StatusForbidden, "room_private", "Room is private, a valid invite code is needed")
+		return
+	}
+
+	spectators := make([]SpectatorResponse, 0, len(room.spectators))
+	for _, spectator := range room.spectators {
+		spectators = append(spectators, SpectatorResponse{
+			Address:  spectator.address,
+			Username: spectator.username,
+		})
+	}
+
+	response := Response{
+		Type:    GetSpectators,
+		Content: spectators,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to encode response")
 		return
 	}
 }
@@ -79,162 +438,719 @@ func (s *Server) postRooms(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		log.Err(err).Msg("Failed to read JoinRoomRequest")
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeBodyError(w, r, err)
 		return
 	}
 
 	var content RoomContent
 	if err := json.Unmarshal(rawContent, &content); err != nil {
 		log.Err(err).Msg("Failed to unmarshall JoinRoomContent")
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "bad_request", "Request content is not a room")
 		return
 	}
 
-	s.MakeRoom(content.Room)
+	timeControl, err := ParseTimeControl(content.TimeControl)
+	if err != nil {
+		log.Err(err).Msg("Invalid time control")
+		writeError(w, http.StatusBadRequest, "invalid_time_control", err.Error())
+		return
+	}
+	if content.TimeControl == "" {
+		timeControl = s.DefaultTimeControl
+	}
+
+	options := RoomOptions{
+		Ranked:           content.Ranked,
+		DisableTakebacks: content.DisableTakebacks,
+		TimeControl:      timeControl,
+		Private:          content.Private,
+		Creator:          clientID(r),
+	}
+
+	if content.Private && content.Password != "" {
+		if options.PasswordHash, err = bcrypt.GenerateFromPassword([]byte(content.Password), bcrypt.DefaultCost); err != nil {
+			log.Err(err).Msg("Failed to hash room password")
+			writeError(w, http.StatusBadRequest, "invalid_password", err.Error())
+			return
+		}
+	}
+
+	room, err := s.MakeRoom(content.Room, options)
+	switch {
+	case errors.Is(err, ErrInvalidRoomName):
+		writeError(w, http.StatusBadRequest, "invalid_room_name", err.Error())
+		return
+	case errors.Is(err, ErrRoomExists):
+		writeError(w, http.StatusConflict, "room_exists", err.Error())
+		return
+	case errors.Is(err, ErrTooManyRooms):
+		s.violation("too_many_rooms", r.RemoteAddr, "Room cap reached")
+		writeError(w, http.StatusServiceUnavailable, "too_many_rooms", err.Error())
+		return
+	case errors.Is(err, ErrNoRoomName):
+		writeError(w, http.StatusServiceUnavailable, "no_room_name", err.Error())
+		return
+	case errors.Is(err, ErrTooManyClientRooms):
+		s.violation("too_many_client_rooms", r.RemoteAddr, "Client's room cap reached")
+		writeError(w, http.StatusTooManyRequests, "too_many_client_rooms", err.Error())
+		return
+	}
+
+	response := Response{
+		Type: CreatedRoom,
+		Content: CreatedRoomResponse{
+			Name:       room.name,
+			InviteCode: room.inviteCode,
+		},
+	}
+
 	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Err(err).Msg("Failed to encode CreatedRoomResponse")
+	}
 }
 
-func (s *Server) StartRESTServer() {
-	s.restRouter = http.NewServeMux()
-	s.restRouter.HandleFunc("GET /rooms", s.getRooms)
-	s.restRouter.HandleFunc("POST /rooms", s.postRooms)
-	err := http.ListenAndServe(":8081", s.restRouter)
-	log.Info().Msg("yo")
+func (s *Server) deleteRoom(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	log.Info().Str("name", name).Msg("DELETE /rooms/{name} request")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room := s.getRoom(name)
+	if room == nil {
+		writeError(w, http.StatusNotFound, "room_not_found", "Room does not exist")
+		return
+	}
+	username := requestUsername(r)
+	if room.options.Creator != "user:"+username && !slices.Contains(s.Admins, username) {
+		writeError(w, http.StatusForbidden, "forbidden", "Only the room's creator or an admin can delete it")
+		return
+	}
+
+	s.closeRoom(room, "Room was deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getInfo(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET /info request")
+
+	_, port, _ := net.SplitHostPort(s.TCPAddr)
+	tcpPort, err := net.LookupPort("tcp", port)
 	if err != nil {
-		log.Err(err).Msg("Failed to start REST server")
+		log.Err(err).Str("address", s.TCPAddr).Msg("Failed to read game server port")
+		writeError(w, http.StatusInternalServerError, "internal", "Game server address is misconfigured")
 		return
 	}
+
+	response := Response{
+		Type: ServerInfo,
+		Content: ServerInfoContent{
+			TCPPort:   tcpPort,
+			WebSocket: "/ws",
+			TLS:       s.TLSConfig != nil,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to encode response")
+	}
+}
+
+func (s *Server) getMetrics(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET /metrics request")
+
+	content := MetricsContent{
+		Violations: make(map[string]int64),
+	}
+	s.violations.Do(func(kv expvar.KeyValue) {
+		content.Violations[kv.Key] = kv.Value.(*expvar.Int).Value()
+	})
+
+	s.mu.Lock()
+	content.Rooms = len(s.Rooms)
+	content.Connections = len(s.conns)
+	s.mu.Unlock()
+
+	response := Response{
+		Type:    Metrics,
+		Content: content,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to encode response")
+	}
+}
+
+// route is a REST endpoint, pattern is a method and path as http.ServeMux
+// takes them. Every route is documented in openapi.json.
+type route struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+func (s *Server) restRoutes() []route {
+	return []route{
+		{"POST /register", s.postRegister},
+		{"POST /login", s.postLogin},
+		{"GET /leaderboard", s.getLeaderboard},
+		{"GET /accounts/{username}/ratings", s.getRatingHistory},
+		{"GET /games", s.withAuth(false, s.getGames)},
+		{"GET /games/{id}", s.withAuth(false, s.getGame)},
+		{"GET /rooms", s.withAuth(false, s.getRooms)},
+		{"POST /rooms", s.withRateLimit(MakeRoom, s.withAuth(false, s.postRooms))},
+		{"DELETE /rooms/{name}", s.withAuth(true, s.deleteRoom)},
+		{"GET /rooms/{name}/spectators", s.withAuth(false, s.getSpectators)},
+		{"GET /rooms/{name}/log", s.withAuth(false, s.getRoomLog)},
+		{"GET /rooms/{name}/events", s.withAuth(false, s.getRoomEvents)},
+		{"POST /rooms/{name}/join", s.withRateLimit(JoinRoom, s.withAuth(false, s.postJoinRoom))},
+		{"POST /rooms/{name}/moves", s.withRateLimit(MakeMove, s.withAuth(false, s.postMove))},
+		{"GET /rooms/{name}/state", s.withAuth(false, s.getRoomState)},
+		{"GET /events", s.withAuth(false, s.getEvents)},
+		{"GET /ws", s.getWebSocket},
+		{"GET /openapi.json", s.getOpenAPI},
+		{"GET /info", s.getInfo},
+		{"GET /metrics", s.getMetrics},
+	}
+}
+
+// routes registers every REST endpoint.
+func (s *Server) routes() *http.ServeMux {
+	s.restRouter = http.NewServeMux()
+	for _, route := range s.restRoutes() {
+		s.restRouter.HandleFunc(route.pattern, route.handler)
+	}
+
+	return s.restRouter
 }
 
 // tcp
 
 func (s *Server) handleConnection(conn net.Conn) {
+	address := conn.RemoteAddr().String()
+	defer conn.Close()
+
+	o, ok := s.trackConnection(conn)
+	if !ok {
+		return
+	}
+	defer func() {
+		s.untrackConnection(conn)
+		<-o.done // flush what's queued before the connection is closed
+	}()
+
+	timeout := s.heartbeatTimeout()
+	if timeout > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go s.heartbeat(conn, done)
+	}
+
+	limiter := &messageLimiter{r: conn}
+	decoder := json.NewDecoder(limiter)
 	for {
 		var rawContent json.RawMessage
 		request := Request{
 			Content: &rawContent,
 		}
 
-		address := conn.RemoteAddr().String()
-		if err := json.NewDecoder(conn).Decode(&request); err != nil {
+		if timeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(timeout))
+		}
+		limiter.left = math.MaxInt64
+		if s.MaxMessageSize > 0 {
+			limiter.left = s.MaxMessageSize
+		}
+		if err := decoder.Decode(&request); err != nil {
+			select {
+			case <-s.closing:
+				return // Shutdown ended the read, seats stay held
+			default:
+			}
+
 			if errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) {
 				log.Info().Str("address", address).Msg("Client disconnected")
-				s.removePlayerFromAll(address)
-				break
+			} else if errors.Is(err, errMessageTooLarge) {
+				s.violation("message_too_large", address, "Request too large, dropping connection")
+				s.mu.Lock()
+				s.sendMessage(conn, Error, ErrorContent{
+					Code:    "message_too_large",
+					Message: fmt.Sprintf("Requests can't be over %d bytes", s.MaxMessageSize),
+				})
+				s.mu.Unlock()
+			} else if isTimeout(err) {
+				log.Info().Str("address", address).Int("missed", s.MaxMissedHeartbeats).Msg("Client stopped answering heartbeats")
+			} else {
+				log.Err(err).Str("address", address).Msg("Failed to read request")
 			}
-			log.Err(err).Msg("Failed to read request")
+			s.dropConnection(conn)
 			return
 		}
 
-		log.Info().Str("type", string(request.Type)).Str("address", address).Msg("Got request")
+		event := log.Info()
+		if request.Type == Pong {
+			event = log.Debug()
+		}
+		event.Str("type", string(request.Type)).Str("address", address).Msg("Got request")
 
-		switch request.Type {
-		case JoinRoom:
-			var content RoomContent
-			err := json.Unmarshal(rawContent, &content)
-			if err != nil {
-				log.Err(err).Msg("Failed to unmarshall JoinRoomContent")
-				return
-			}
+		s.mu.Lock()
+		if s.shuttingDown {
+			s.sendError(conn, "Server is shutting down")
+		} else if wait, ok := s.takeToken(request.Type, address); !ok {
+			s.sendRateLimited(conn, request.Type, wait)
+		} else {
+			s.handleRequest(conn, request.Type, rawContent)
+		}
+		s.mu.Unlock()
+	}
+}
 
-			room := s.GetRoom(content.Room)
-			if room == nil {
-				return
-			}
+// handleRequest must be called with s.mu held.
+func (s *Server) handleRequest(conn net.Conn, requestType RequestType, rawContent json.RawMessage) {
+	address := conn.RemoteAddr().String()
 
-			mark := "X"
+	if requestType == Authenticate {
+		var content AuthenticateContent
+		if err := json.Unmarshal(rawContent, &content); err != nil {
+			log.Err(err).Msg("Failed to unmarshall AuthenticateContent")
+			s.sendError(conn, "Malformed Authenticate request")
+			return
+		}
 
-			if len(room.players) == 1 {
-				mark = "O"
-				room.started = true
-			} else if len(room.players) > 1 {
-				log.Info().Str("name", content.Room).Msg("Room is full")
-				return
-			}
+		username, err := s.VerifyToken(content.Token)
+		if err != nil {
+			s.sendError(conn, err.Error())
+			return
+		}
 
-			room.players[address] = NewPlayer(mark, conn)
-			responseContent := AssignMarkContent{
-				Room:   content.Room,
-				Player: mark,
-			}
-			s.sendMessage(conn, AssignMark, responseContent)
-			time.Sleep(150)
-			s.broadcastUpdates(content.Room)
-
-			log.Info().Str("address", address).Str("mark", mark).Str("room", content.Room).Msg("Player joined room")
-		case MakeMove:
-			var content MakeMoveContent
-			err := json.Unmarshal(rawContent, &content)
-			if err != nil {
-				log.Err(err).Msg("Failed to unmarshall MakeMoveContent")
-				return
-			}
+		s.sessions[conn] = username
+		s.sendMessage(conn, Authenticated, SessionContent{Username: username})
+		log.Info().Str("address", address).Str("username", username).Msg("Connection authenticated")
+		return
+	}
 
-			room := s.GetRoom(content.Room)
-			if room == nil {
-				return
-			}
+	if requestType == Pong {
+		var content HeartbeatContent
+		if err := json.Unmarshal(rawContent, &content); err != nil {
+			log.Err(err).Msg("Failed to unmarshall HeartbeatContent")
+			s.sendError(conn, "Malformed Pong request")
+			return
+		}
 
-			if len(room.players) >= 2 { // setting this greater or equal for now, should be equal
-				if room.game.Turn == content.Player {
-					room.game.Move(content.Move)
-					log.Info().Str("move", content.Move).Str("room", room.name).Msg("Made move")
-				}
+		s.handlePong(conn, content)
+		return
+	}
+
+	if _, ok := s.sessions[conn]; !ok && s.RequireAuth {
+		s.sendError(conn, "Authentication required, log in first")
+		return
+	}
+
+	switch requestType {
+	case JoinRoom:
+		var content RoomContent
+		err := json.Unmarshal(rawContent, &content)
+		if err != nil {
+			log.Err(err).Msg("Failed to unmarshall JoinRoomContent")
+			s.sendError(conn, "Malformed JoinRoom request")
+			return
+		}
+
+		room := s.enterRoom(conn, content)
+		if room == nil {
+			return
+		}
+		if room.playerByConn(conn) != nil {
+			s.sendError(conn, "You already have a seat in this room")
+			return
+		}
+
+		mark, err := s.openSeat(room)
+		if err != nil {
+			log.Info().Str("name", room.name).Msg("Room is full")
+			s.sendError(conn, "Room is full")
+			return
+		}
+
+		s.seatPlayer(room, conn, mark)
+		s.broadcastUpdates(room.name)
+
+		log.Info().Str("address", address).Str("mark", mark).Str("room", room.name).Msg("Player joined room")
+	case Resume:
+		var content ResumeContent
+		err := json.Unmarshal(rawContent, &content)
+		if err != nil {
+			log.Err(err).Msg("Failed to unmarshall ResumeContent")
+			s.sendError(conn, "Malformed Resume request")
+			return
+		}
+
+		room := s.getRoom(content.Room)
+		if room == nil {
+			s.sendError(conn, "Room does not exist")
+			return
+		}
+
+		player, ok := room.players[content.Token]
+		if !ok {
+			log.Info().Str("address", address).Str("room", content.Room).Msg("Unknown session token")
+			s.sendError(conn, "Session has expired")
+			return
+		}
+
+		if player.dropTimer != nil {
+			player.dropTimer.Stop()
+			player.dropTimer = nil
+		}
+		if player.connected() && player.connection != conn {
+			// The new connection takes over the seat, closing can block on a
+			// WebSocket or TLS goodbye.
+			go player.connection.Close()
+		}
+		player.connection = conn
+		player.address = address
+
+		s.sendMessage(conn, AssignMark, AssignMarkContent{
+			Room:   room.name,
+			Player: player.mark,
+			Token:  player.token,
+		})
+		s.broadcastUpdates(room.name)
+
+		log.Info().Str("address", address).Str("mark", player.mark).Str("room", room.name).Msg("Player resumed session")
+	case Spectate:
+		var content RoomContent
+		err := json.Unmarshal(rawContent, &content)
+		if err != nil {
+			log.Err(err).Msg("Failed to unmarshall SpectateContent")
+			s.sendError(conn, "Malformed Spectate request")
+			return
+		}
+
+		room := s.enterRoom(conn, content)
+		if room == nil {
+			return
+		}
+
+		spectator := NewSpectator(conn)
+		spectator.username = s.sessions[conn]
+		room.spectators[conn] = spectator
+		s.sendMessage(conn, Spectating, RoomContent{Room: room.name})
+		s.broadcastUpdates(room.name)
+
+		log.Info().Str("address", address).Str("room", room.name).Msg("Spectator joined room")
+	case QuickMatch:
+		s.handleQuickMatch(conn, rawContent)
+	case CancelQuickMatch:
+		if s.dequeue(conn) {
+			log.Info().Str("address", address).Msg("Player left quick match queue")
+		}
+	case LeaveRoom:
+		var content RoomContent
+		err := json.Unmarshal(rawContent, &content)
+		if err != nil {
+			log.Err(err).Msg("Failed to unmarshall LeaveRoomContent")
+			s.sendError(conn, "Malformed LeaveRoom request")
+			return
+		}
+
+		room := s.getRoom(content.Room)
+		if room == nil {
+			s.sendError(conn, "Room does not exist")
+			return
+		}
+
+		if !s.leaveRoom(room, conn) {
+			s.sendError(conn, "You are not in this room")
+			return
+		}
+		s.broadcastUpdates(room.name)
+	case MakeMove:
+		var content MakeMoveContent
+		err := json.Unmarshal(rawContent, &content)
+		if err != nil {
+			log.Err(err).Msg("Failed to unmarshall MakeMoveContent")
+			s.sendError(conn, "Malformed MakeMove request")
+			return
+		}
+
+		room := s.getRoom(content.Room)
+		if room == nil {
+			s.sendError(conn, "Room does not exist")
+			return
+		}
+
+		player := room.playerByConn(conn)
+		if player == nil {
+			s.sendError(conn, "You are not seated in this room")
+			return
+		}
+
+		if err := s.makeMove(room, player, content.Move); err != nil {
+			log.Info().Err(err).Str("move", content.Move).Str("room", room.name).Msg("Refused move")
+		}
+
+		s.broadcastUpdates(content.Room)
+	case Resign:
+		room, player := s.seatFor(conn, rawContent)
+		if room == nil {
+			return
+		}
+		if !room.started || room.game.HasWinner() {
+			s.sendError(conn, "There is no game in progress")
+			return
+		}
+
+		room.stopClock()
+		room.game.Resign(player.mark)
+		room.drawOffer = ""
+		room.takebackOffer = ""
+		room.record(Event{Type: ResignedEvent, Mark: player.mark})
+		s.broadcastUpdates(room.name)
+
+		log.Info().Str("room", room.name).Str("mark", player.mark).Msg("Player resigned")
+	case OfferDraw:
+		room, player := s.seatFor(conn, rawContent)
+		if room == nil {
+			return
+		}
+		if !room.started || room.game.HasWinner() {
+			s.sendError(conn, "There is no game in progress")
+			return
+		}
+		if room.drawOffer != "" {
+			s.sendError(conn, "A draw has already been offered")
+			return
+		}
+
+		room.drawOffer = player.mark
+		room.record(Event{Type: DrawOfferedEvent, Mark: player.mark})
+		s.broadcastUpdates(room.name)
+
+		log.Info().Str("room", room.name).Str("mark", player.mark).Msg("Player offered draw")
+	case AcceptDraw, DeclineDraw:
+		room, player := s.seatFor(conn, rawContent)
+		if room == nil {
+			return
+		}
+		if room.drawOffer == "" || room.drawOffer == player.mark {
+			s.sendError(conn, "There is no draw offer to answer")
+			return
+		}
+
+		if requestType == AcceptDraw {
+			room.stopClock()
+			room.game.AgreeDraw()
+			room.takebackOffer = ""
+			room.record(Event{Type: DrawAgreedEvent, Mark: player.mark})
+		} else {
+			room.record(Event{Type: DrawDeclinedEvent, Mark: player.mark})
+		}
+		room.drawOffer = ""
+		s.broadcastUpdates(room.name)
+
+		log.Info().Str("room", room.name).Str("mark", player.mark).Str("answer", string(requestType)).Msg("Player answered draw offer")
+	case RequestTakeback:
+		room, player := s.seatFor(conn, rawContent)
+		if room == nil {
+			return
+		}
+		if !room.takebacksAllowed() {
+			s.sendError(conn, "Takebacks are disabled in this room")
+			return
+		}
+		if room.game.HasWinner() || room.game.LastMover() != player.mark {
+			s.sendError(conn, "You can only take back your own last move")
+			return
+		}
+
+		room.takebackOffer = player.mark
+		room.record(Event{Type: TakebackRequestedEvent, Mark: player.mark})
+		s.broadcastUpdates(room.name)
+
+		log.Info().Str("room", room.name).Str("mark", player.mark).Msg("Player requested takeback")
+	case AcceptTakeback, DeclineTakeback:
+		room, player := s.seatFor(conn, rawContent)
+		if room == nil {
+			return
+		}
+		if room.takebackOffer == "" || room.takebackOffer == player.mark {
+			s.sendError(conn, "There is no takeback request to answer")
+			return
+		}
+		if requestType == AcceptTakeback && room.game.HasWinner() {
+			room.takebackOffer = ""
+			s.sendError(conn, "The game is over")
+			return
+		}
+
+		if requestType == AcceptTakeback {
+			if !room.chargeClock() {
+				s.timeOut(room)
+			} else {
+				room.game.Undo()
+				room.record(Event{Type: TakebackAcceptedEvent, Mark: player.mark})
+				room.restartTurn()
 			}
+		} else {
+			room.record(Event{Type: TakebackDeclinedEvent, Mark: player.mark})
+		}
+		room.takebackOffer = ""
+		s.broadcastUpdates(room.name)
+
+		log.Info().Str("room", room.name).Str("mark", player.mark).Str("answer", string(requestType)).Msg("Player answered takeback request")
+	case RequestRematch:
+		room, player := s.seatFor(conn, rawContent)
+		if room == nil {
+			return
+		}
+		if !room.game.HasWinner() {
+			s.sendError(conn, "The game isn't over yet")
+			return
+		}
+
+		room.rematchOffer = player.mark
+		room.record(Event{Type: RematchRequestedEvent, Mark: player.mark})
+		s.broadcastUpdates(room.name)
+
+		log.Info().Str("room", room.name).Str("mark", player.mark).Msg("Player requested rematch")
+	case AcceptRematch:
+		room, player := s.seatFor(conn, rawContent)
+		if room == nil {
+			return
+		}
+		if room.rematchOffer == "" || room.rematchOffer == player.mark {
+			s.sendError(conn, "There is no rematch request to accept")
+			return
+		}
+		if len(room.players) < 2 {
+			s.sendError(conn, "Your opponent has left the room")
+			return
+		}
 
-			s.broadcastUpdates(content.Room)
+		s.archiveGame(room)
+		room.rematch()
+		room.startClock(s.flagFunc(room.name))
+		for _, seated := range room.players {
+			if !seated.connected() {
+				continue
+			}
+			s.sendMessage(seated.connection, AssignMark, AssignMarkContent{
+				Room:   room.name,
+				Player: seated.mark,
+				Token:  seated.token,
+			})
 		}
+		s.broadcastUpdates(room.name)
+
+		log.Info().Str("room", room.name).Msg("Started rematch")
+	default:
+		s.sendError(conn, "Unsupported request type "+string(requestType))
 	}
 }
 
-func (s *Server) sendMessage(conn net.Conn, responseType ResponseType, content any) {
-	res := Response{
-		Type:    responseType,
-		Content: content,
-	}
+// seatPlayer gives conn a seat with mark in room and tells it its mark and
+// session token.
+func (s *Server) seatPlayer(room *Room, conn net.Conn, mark string) *Player {
+	player := NewPlayer(mark, conn)
+	player.username = s.sessions[conn]
+	addSeat(room, player)
 
-	encoder := json.NewEncoder(conn)
-	err := encoder.Encode(res)
-	if err != nil {
-		log.Err(err).Msg("Failed to encode message")
-		return
-	}
+	s.sendMessage(conn, AssignMark, AssignMarkContent{
+		Room:   room.name,
+		Player: mark,
+		Token:  player.token,
+	})
+	return player
 }
 
-func (s *Server) broadcastUpdates(roomName string) {
-	for _, room := range s.Rooms {
-		if room.name == roomName {
-			for _, player := range room.players {
-				s.sendMessage(player.connection, UpdateGame, UpdateGameContent{
-					Game:    *room.game,
-					Started: room.started,
-				})
-			}
+// flagFunc returns the callback a room's flag timer runs when the player on
+// move may have run out of time.
+func (s *Server) flagFunc(roomName string) func() {
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		room, ok := s.Rooms[roomName]
+		if !ok || !room.clockRunning {
+			return
 		}
+
+		if room.timeLeft(room.game.Turn) > 0 {
+			room.restartTurn() // the timer raced a move, wait for the new turn
+			return
+		}
+
+		s.timeOut(room)
+		s.broadcastUpdates(roomName)
 	}
 }
 
-func (s *Server) StartTCPServer() {
-	var err error
-	if s.tcpListener, err = net.Listen("tcp", ":8080"); err != nil {
-		log.Error().Err(err).Msg("Failed to start listener")
+// timeOut ends the game in room with the player on move losing on time.
+func (s *Server) timeOut(room *Room) {
+	loser := room.game.Turn
+	room.stopClock()
+	room.game.Timeout(loser)
+	room.drawOffer = ""
+	room.takebackOffer = ""
+	room.record(Event{Type: TimedOutEvent, Mark: loser})
+
+	log.Info().Str("room", room.name).Str("mark", loser).Msg("Player ran out of time")
+}
+
+// seatFor decodes a RoomContent request and finds the seat conn holds in
+// that room, replying with an error and returning nil if there isn't one.
+func (s *Server) seatFor(conn net.Conn, rawContent json.RawMessage) (*Room, *Player) {
+	var content RoomContent
+	if err := json.Unmarshal(rawContent, &content); err != nil {
+		log.Err(err).Msg("Failed to unmarshall RoomContent")
+		s.sendError(conn, "Malformed request")
+		return nil, nil
 	}
 
-	defer func(listen net.Listener) {
-		if err := listen.Close(); err != nil {
-			log.Fatal().Err(err).Msg("deferred listener")
-		}
-	}(s.tcpListener)
+	room := s.getRoom(content.Room)
+	if room == nil {
+		s.sendError(conn, "Room does not exist")
+		return nil, nil
+	}
 
-	log.Info().Msg("Started tcp server on port 8080")
-	func() {
-		for {
-			conn, err := s.tcpListener.Accept()
-			if err != nil {
-				log.Fatal().Err(err).Msg("Failed to accept connection")
+	player := room.playerByConn(conn)
+	if player == nil {
+		s.sendError(conn, "You are not seated in this room")
+		return nil, nil
+	}
+
+	return room, player
+}
+
+func (s *Server) sendError(conn net.Conn, message string) {
+	s.sendMessage(conn, Error, ErrorContent{Message: message})
+}
+
+func (s *Server) broadcastUpdates(roomName string) {
+	for _, room := range s.Rooms {
+		if room.name == roomName {
+			if room.touch() {
+				s.rateGame(room)
+				s.archiveGame(room)
 			}
+			room.version++
+			close(room.changed)
+			room.changed = make(chan struct{})
 
-			go s.handleConnection(conn)
+			content := room.updateContent()
+			content.Ratings = s.roomRatings(room)
+			s.roomSubs[roomName].publish(GameUpdateSSE, content)
+			s.publishLobby(RoomUpdatedSSE, room)
+			for _, player := range room.players {
+				if !player.connected() {
+					continue
+				}
+				s.sendMessage(player.connection, UpdateGame, content)
+			}
+			for _, spectator := range room.spectators {
+				s.sendMessage(spectator.connection, UpdateGame, content)
+			}
 		}
-	}()
+	}
 }
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/tylerolson/tictacgo/store"
+)
+
+func TestMain(m *testing.M) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+// startTestServer starts s on free local ports and shuts it down when the
+// test ends.
+func startTestServer(t *testing.T, s *Server) {
+	t.Helper()
+
+	s.TCPAddr, s.RESTAddr = "127.0.0.1:0", "127.0.0.1:0"
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.Shutdown(ctx)
+	})
+}
+
+// dialGame connects to s's game server without a Client, so nothing is read
+// or answered unless the test does it.
+func dialGame(t *testing.T, s *Server) net.Conn {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", s.TCPAddr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func sendRequest(t *testing.T, conn net.Conn, requestType RequestType, content any) {
+	t.Helper()
+
+	if err := json.NewEncoder(conn).Encode(Request{Type: requestType, Content: content}); err != nil {
+		t.Fatalf("send %s: %v", requestType, err)
+	}
+}
+
+// eventually fails the test if cond isn't true within timeout.
+func eventually(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// withLock runs f with s.mu held.
+func withLock[T any](s *Server, f func() T) T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return f()
+}
+
+func TestDeleteRoom(t *testing.T) {
+	st := store.NewMemory()
+	s, err := NewServerWithStore(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Admins = []string{"carol"}
+
+	tokens := map[string]string{}
+	for _, username := range []string{"alice", "bob", "carol"} {
+		if err := s.Register(username, "password123"); err != nil {
+			t.Fatal(err)
+		}
+		session, err := s.Login(username, "password123")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[username] = session.Token
+	}
+	for name, creator := range map[string]string{"alices": "user:alice", "bobs": "user:bob", "guests": "ip:127.0.0.1"} {
+		if _, err := s.MakeRoom(name, RoomOptions{Creator: creator}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the creator has to survive a restart to be checked
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	restarted, err := NewServerWithStore(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.TokenSecret = s.TokenSecret
+	restarted.Admins = s.Admins
+	router := restarted.routes()
+
+	tests := []struct {
+		room, username string
+		want           int
+	}{
+		{"alices", "", http.StatusUnauthorized},
+		{"alices", "bob", http.StatusForbidden},
+		{"guests", "bob", http.StatusForbidden},
+		{"alices", "alice", http.StatusNoContent},
+		{"bobs", "carol", http.StatusNoContent},
+		{"guests", "carol", http.StatusNoContent},
+		{"guests", "carol", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodDelete, "/rooms/"+test.room, nil)
+		if test.username != "" {
+			r.Header.Set("Authorization", "Bearer "+tokens[test.username])
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != test.want {
+			t.Errorf("%q deleting %s = %d, want %d", test.username, test.room, w.Code, test.want)
+		}
+	}
+}
+
+func TestUnsupportedRequestType(t *testing.T) {
+	s := NewServer()
+	s.HeartbeatInterval = 0
+	startTestServer(t, s)
+	conn := dialGame(t, s)
+
+	sendRequest(t, conn, "Register", AuthenticateContent{})
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var content ErrorContent
+	response := Response{Content: &content}
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		t.Fatalf("no response: %v", err)
+	}
+	if response.Type != Error || content.Message != "Unsupported request type Register" {
+		t.Errorf("got %s %+v, want an unsupported request error", response.Type, content)
+	}
+}
@@ -0,0 +1,300 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tylerolson/tictacgo"
+)
+
+const (
+	// SnapshotVersion is the version of the snapshots this server writes.
+	SnapshotVersion = 1
+
+	DefaultSnapshotInterval = time.Minute
+)
+
+// snapshotUpgrades bring an older snapshot up to date, snapshotUpgrades[i]
+// turns a version i+1 snapshot into a version i+2 one. Only ever append to
+// this list and bump SnapshotVersion with it.
+var snapshotUpgrades = []func(raw map[string]json.RawMessage) error{}
+
+// Snapshot is the live state of a server, written so games in progress
+// survive a restart.
+type Snapshot struct {
+	Version int            `json:"version"`
+	Taken   time.Time      `json:"taken"`
+	Rooms   []RoomSnapshot `json:"rooms"`
+}
+
+type RoomSnapshot struct {
+	Name             string        `json:"name"`
+	Ranked           bool          `json:"ranked"`
+	DisableTakebacks bool          `json:"disableTakebacks"`
+	TimeControl      string        `json:"timeControl"`
+	Private          bool          `json:"private"`
+	PasswordHash     []byte        `json:"passwordHash"`
+	InviteCode       string        `json:"inviteCode"`
+	Creator          string        `json:"creator,omitempty"`
+	Permanent        bool          `json:"permanent,omitempty"`
+	Game             tictacgo.Game `json:"game"`
+	Started          bool          `json:"started"`
+	DrawOffer        string        `json:"drawOffer"`
+	RematchOffer     string        `json:"rematchOffer"`
+	TakebackOffer    string        `json:"takebackOffer"`
+	FinishedAt       time.Time     `json:"finishedAt"`
+	Archived         bool          `json:"archived"`
+
+	// Clocks hold the time left when the snapshot was taken, the player on
+	// move gets their turn restarted when it is restored.
+	Clocks       map[string]time.Duration `json:"clocks"`
+	ClockRunning bool                     `json:"clockRunning"`
+
+	Seats []SeatSnapshot `json:"seats"`
+
+	// Events is the room's log, older snapshots don't have it.
+	Events []Event `json:"events,omitempty"`
+}
+
+// SeatSnapshot is a seat a player can resume with their session token.
+type SeatSnapshot struct {
+	Mark     string `json:"mark"`
+	Token    string `json:"token"`
+	Username string `json:"username"`
+}
+
+// Snapshot captures every room, game, seat and clock.
+func (s *Server) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := Snapshot{
+		Version: SnapshotVersion,
+		Taken:   time.Now(),
+		Rooms:   make([]RoomSnapshot, 0, len(s.Rooms)),
+	}
+
+	for _, room := range s.Rooms {
+		rs := RoomSnapshot{
+			Name:             room.name,
+			Ranked:           room.options.Ranked,
+			DisableTakebacks: room.options.DisableTakebacks,
+			TimeControl:      room.options.TimeControl.String(),
+			Private:          room.options.Private,
+			PasswordHash:     room.options.PasswordHash,
+			InviteCode:       room.inviteCode,
+			Creator:          room.options.Creator,
+			Permanent:        room.options.Permanent,
+			Game:             *room.game,
+			Started:          room.started,
+			DrawOffer:        room.drawOffer,
+			RematchOffer:     room.rematchOffer,
+			TakebackOffer:    room.takebackOffer,
+			FinishedAt:       room.finishedAt,
+			Archived:         room.archived,
+			ClockRunning:     room.clockRunning,
+			Seats:            make([]SeatSnapshot, 0, len(room.players)),
+			Events:           room.Events(),
+		}
+		if room.clocks != nil {
+			rs.Clocks = make(map[string]time.Duration, len(room.clocks))
+			for mark, left := range room.clocks {
+				if room.clockRunning && mark == room.game.Turn && room.options.TimeControl.Initial > 0 {
+					left -= time.Since(room.turnStarted)
+				}
+				rs.Clocks[mark] = left
+			}
+		}
+		for _, player := range room.players {
+			rs.Seats = append(rs.Seats, SeatSnapshot{
+				Mark:     player.mark,
+				Token:    player.token,
+				Username: player.username,
+			})
+		}
+
+		snapshot.Rooms = append(snapshot.Rooms, rs)
+	}
+
+	return snapshot
+}
+
+// Restore brings back the rooms in snapshot, replacing any with the same
+// name. Every seat is held for SeatGracePeriod for its player to resume.
+func (s *Server) Restore(snapshot Snapshot) error {
+	if snapshot.Version != SnapshotVersion {
+		return fmt.Errorf("can't restore a version %d snapshot, upgrade it first", snapshot.Version)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rs := range snapshot.Rooms {
+		timeControl, err := ParseTimeControl(rs.TimeControl)
+		if err != nil {
+			return fmt.Errorf("room %q has a bad time control\n%w", rs.Name, err)
+		}
+
+		if old, ok := s.Rooms[rs.Name]; ok {
+			old.stopClock()
+		}
+
+		game := rs.Game
+		room := NewRoom(rs.Name, RoomOptions{
+			Ranked:           rs.Ranked,
+			DisableTakebacks: rs.DisableTakebacks,
+			TimeControl:      timeControl,
+			Private:          rs.Private,
+			PasswordHash:     rs.PasswordHash,
+			Creator:          rs.Creator,
+			Permanent:        rs.Permanent,
+		})
+		room.inviteCode = rs.InviteCode
+		room.game = &game
+		room.started = rs.Started
+		room.drawOffer = rs.DrawOffer
+		room.rematchOffer = rs.RematchOffer
+		room.takebackOffer = rs.TakebackOffer
+		room.finishedAt = rs.FinishedAt
+		room.archived = rs.Archived
+		room.clocks = rs.Clocks
+		if len(rs.Events) > 0 {
+			room.events = rs.Events
+		}
+
+		for _, seat := range rs.Seats {
+			player := &Player{
+				mark:     seat.Mark,
+				token:    seat.Token,
+				username: seat.Username,
+			}
+			player.dropTimer = time.AfterFunc(s.SeatGracePeriod, func() {
+				s.releaseSeat(room.name, player.token)
+			})
+			room.players[player.token] = player
+		}
+
+		if rs.ClockRunning && room.clocks != nil {
+			room.onFlag = s.flagFunc(room.name)
+			room.clockRunning = true
+			room.restartTurn()
+		}
+
+		if len(rs.Events) > 0 {
+			if replayed, err := Replay(room.events); err != nil || !reflect.DeepEqual(replayed, room.State()) {
+				log.Warn().Err(err).Str("room", room.name).Msg("Snapshot room doesn't match its event log")
+			}
+		}
+
+		s.Rooms[room.name] = room
+		s.saveRoom(room)
+	}
+
+	log.Info().Int("rooms", len(snapshot.Rooms)).Time("taken", snapshot.Taken).Msg("Restored snapshot")
+	return nil
+}
+
+// WriteSnapshot writes a snapshot to path, replacing the old one only once the
+// new one is completely written.
+func (s *Server) WriteSnapshot(path string) error {
+	data, err := json.Marshal(s.Snapshot())
+	if err != nil {
+		return fmt.Errorf("couldn't encode snapshot\n%w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("couldn't write snapshot\n%w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("couldn't write snapshot\n%w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("couldn't write snapshot\n%w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("couldn't replace snapshot\n%w", err)
+	}
+
+	log.Debug().Str("path", path).Msg("Wrote snapshot")
+	return nil
+}
+
+// RestoreSnapshot restores the snapshot at path, upgrading it if it was
+// written by an older server. A missing file is not an error.
+func (s *Server) RestoreSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("couldn't read snapshot\n%w", err)
+	}
+
+	snapshot, err := decodeSnapshot(data)
+	if err != nil {
+		return err
+	}
+	return s.Restore(snapshot)
+}
+
+// decodeSnapshot reads a snapshot of any version up to SnapshotVersion.
+func decodeSnapshot(data []byte) (Snapshot, error) {
+	var snapshot Snapshot
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return snapshot, fmt.Errorf("snapshot is not valid JSON\n%w", err)
+	}
+
+	var version int
+	if err := json.Unmarshal(raw["version"], &version); err != nil || version < 1 {
+		return snapshot, errors.New("snapshot has no version")
+	}
+	if version > SnapshotVersion {
+		return snapshot, fmt.Errorf("snapshot version %d is newer than this server understands (%d)", version, SnapshotVersion)
+	}
+
+	for ; version < SnapshotVersion; version++ {
+		if err := snapshotUpgrades[version-1](raw); err != nil {
+			return snapshot, fmt.Errorf("couldn't upgrade snapshot from version %d\n%w", version, err)
+		}
+	}
+	raw["version"] = json.RawMessage(fmt.Sprint(SnapshotVersion))
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return snapshot, err
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("couldn't decode snapshot\n%w", err)
+	}
+	return snapshot, nil
+}
+
+// StartSnapshots writes a snapshot to path every SnapshotInterval until the
+// server shuts down.
+func (s *Server) StartSnapshots(path string) {
+	ticker := time.NewTicker(s.SnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.WriteSnapshot(path); err != nil {
+			log.Error().Err(err).Msg("Failed to write snapshot")
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotLeavesOutTokenSecret(t *testing.T) {
+	s := NewServer()
+	if _, err := s.MakeRoom("r", RoomOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := s.WriteSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte(base64.StdEncoding.EncodeToString(s.TokenSecret))) || bytes.Contains(data, []byte("tokenSecret")) {
+		t.Error("snapshot holds the token secret")
+	}
+
+	restarted := NewServer()
+	secret := restarted.TokenSecret
+	if err := restarted.RestoreSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(restarted.TokenSecret, secret) {
+		t.Error("restoring a snapshot changed the token secret")
+	}
+	if restarted.GetRoom("r") == nil {
+		t.Error("room wasn't restored")
+	}
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// sseBuffer is how many events a stream may fall behind by before it is
+	// dropped, the client is expected to reconnect.
+	sseBuffer = 32
+
+	sseKeepAlive = 15 * time.Second
+)
+
+// Lobby stream events, the data of each is a RoomResponse except for
+// RoomsEvent, which lists every public room when a stream starts.
+const (
+	RoomsEvent       = "rooms"
+	RoomCreatedSSE   = "room_created"
+	RoomUpdatedSSE   = "room_updated"
+	RoomClosedSSE    = "room_closed"
+	GameUpdateSSE    = "update" // per room stream, data is UpdateGameContent
+	RoomStreamClosed = "closed" // per room stream, data is RoomClosedContent
+)
+
+type sseEvent struct {
+	name string
+	data []byte
+}
+
+// subscribers holds the open event streams, it is guarded by s.mu.
+type subscribers map[chan sseEvent]struct{}
+
+// publish sends an event to every stream in subs without waiting, streams
+// that have fallen too far behind are dropped.
+func (subs subscribers) publish(name string, content any) {
+	if len(subs) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		log.Err(err).Str("event", name).Msg("Failed to encode event")
+		return
+	}
+
+	for ch := range subs {
+		select {
+		case ch <- sseEvent{name: name, data: data}:
+		default:
+			delete(subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// publishLobby tells lobby streams about a change to a public room, it must
+// be called with s.mu held.
+func (s *Server) publishLobby(name string, room *Room) {
+	if room.options.Private {
+		return
+	}
+	s.lobbySubs.publish(name, room.response())
+}
+
+// getEvents streams room created, updated and closed events for the lobby.
+func (s *Server) getEvents(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET /events request")
+
+	ch := make(chan sseEvent, sseBuffer)
+
+	s.mu.Lock()
+	rooms := make([]RoomResponse, 0, len(s.Rooms))
+	for _, room := range s.Rooms {
+		if !room.options.Private {
+			rooms = append(rooms, room.response())
+		}
+	}
+	s.lobbySubs[ch] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.lobbySubs, ch)
+		s.mu.Unlock()
+	}()
+
+	first, _ := json.Marshal(rooms)
+	streamEvents(w, r, sseEvent{name: RoomsEvent, data: first}, ch)
+}
+
+// getRoomEvents streams a room's game updates, the same content players and
+// spectators get over the game protocol. Private rooms need ?code=.
+func (s *Server) getRoomEvents(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	log.Info().Str("name", name).Msg("GET /rooms/{name}/events request")
+
+	ch := make(chan sseEvent, sseBuffer)
+
+	s.mu.Lock()
+	room := s.getRoom(name)
+	if room == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "room_not_found", "Room does not exist")
+		return
+	}
+	if !canView(r, room) {
+		s.mu.Unlock()
+		writeError(w, http.StatusForbidden, "room_private", "Room is private, a valid invite code is needed")
+		return
+	}
+
+	content := room.updateContent()
+	content.Ratings = s.roomRatings(room)
+	if s.roomSubs[name] == nil {
+		s.roomSubs[name] = make(subscribers)
+	}
+	s.roomSubs[name][ch] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		if subs, ok := s.roomSubs[name]; ok {
+			delete(subs, ch)
+			if len(subs) == 0 {
+				delete(s.roomSubs, name)
+			}
+		}
+		s.mu.Unlock()
+	}()
+
+	first, _ := json.Marshal(content)
+	streamEvents(w, r, sseEvent{name: GameUpdateSSE, data: first}, ch)
+}
+
+// streamEvents writes first and then everything sent on ch until the client
+// goes away or ch is closed.
+func streamEvents(w http.ResponseWriter, r *http.Request, first sseEvent, ch chan sseEvent) {
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{}) // streams outlive the server's write timeout
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !writeEvent(w, rc, first) {
+		return
+	}
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		case event, ok := <-ch:
+			if !ok || !writeEvent(w, rc, event) {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, rc *http.ResponseController, event sseEvent) bool {
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.name, event.data); err != nil {
+		return false
+	}
+	return rc.Flush() == nil
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tylerolson/tictacgo/store"
+)
+
+// queueWrite runs write against the store, it must be called with s.mu held.
+// Writes run one at a time in the order they were queued, without s.mu, so a
+// slow disk never holds up the server.
+func (s *Server) queueWrite(write func()) {
+	s.writes = append(s.writes, write)
+	if !s.writing {
+		s.writing = true
+		go s.runWrites()
+	}
+}
+
+// runWrites runs queued writes until there are none left.
+func (s *Server) runWrites() {
+	for {
+		s.mu.Lock()
+		writes := s.writes
+		s.writes = nil
+		if len(writes) == 0 {
+			s.writing = false
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+
+		for _, write := range writes {
+			write()
+		}
+	}
+}
+
+// flushWrites waits for every write queued so far to finish.
+func (s *Server) flushWrites() {
+	done := make(chan struct{})
+	s.mu.Lock()
+	s.queueWrite(func() { close(done) })
+	s.mu.Unlock()
+	<-done
+}
+
+// saveRoom records room's settings so it can be reopened after a restart, it
+// must be called with s.mu held.
+func (s *Server) saveRoom(room *Room) {
+	record := store.RoomRecord{
+		Name:             room.name,
+		Ranked:           room.options.Ranked,
+		DisableTakebacks: room.options.DisableTakebacks,
+		TimeControl:      room.options.TimeControl.String(),
+		Private:          room.options.Private,
+		PasswordHash:     room.options.PasswordHash,
+		InviteCode:       room.inviteCode,
+		Creator:          room.options.Creator,
+		Permanent:        room.options.Permanent,
+		Created:          time.Now(),
+	}
+	s.queueWrite(func() {
+		if err := s.store.SaveRoom(record); err != nil {
+			log.Error().Err(err).Str("room", record.Name).Msg("Failed to save room")
+		}
+	})
+}
+
+// restoreRooms reopens every room in the store with a fresh game.
+func (s *Server) restoreRooms() error {
+	records, err := s.store.ListRooms()
+	if err != nil {
+		return fmt.Errorf("couldn't load rooms\n%w", err)
+	}
+
+	for _, record := range records {
+		timeControl, err := ParseTimeControl(record.TimeControl)
+		if err != nil {
+			log.Warn().Err(err).Str("room", record.Name).Msg("Skipping stored room with a bad time control")
+			continue
+		}
+
+		room := NewRoom(record.Name, RoomOptions{
+			Ranked:           record.Ranked,
+			DisableTakebacks: record.DisableTakebacks,
+			TimeControl:      timeControl,
+			Private:          record.Private,
+			PasswordHash:     record.PasswordHash,
+			Creator:          record.Creator,
+			Permanent:        record.Permanent,
+		})
+		room.inviteCode = record.InviteCode
+		s.Rooms[room.name] = room
+	}
+
+	if len(records) > 0 {
+		log.Info().Int("rooms", len(s.Rooms)).Msg("Restored rooms from store")
+	}
+	return nil
+}
+
+// Close finishes any queued writes and closes the server's store.
+func (s *Server) Close() error {
+	s.flushWrites()
+	return s.store.Close()
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tylerolson/tictacgo/store"
+)
+
+// slowStore holds every SaveRoom until release is closed.
+type slowStore struct {
+	*store.Memory
+	release chan struct{}
+}
+
+func (s slowStore) SaveRoom(room store.RoomRecord) error {
+	<-s.release
+	return s.Memory.SaveRoom(room)
+}
+
+func TestStoreWritesDontHoldTheLock(t *testing.T) {
+	st := slowStore{Memory: store.NewMemory(), release: make(chan struct{})}
+	s, err := NewServerWithStore(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	made := make(chan error)
+	go func() {
+		_, err := s.MakeRoom("r", RoomOptions{})
+		made <- err
+	}()
+	select {
+	case err := <-made:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("MakeRoom waited for the store")
+	}
+	if s.GetRoom("r") == nil {
+		t.Fatal("room wasn't made")
+	}
+
+	close(st.release)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if rooms, err := st.ListRooms(); err != nil || len(rooms) != 1 {
+		t.Errorf("store has %d rooms after Close, %v, want 1", len(rooms), err)
+	}
+}
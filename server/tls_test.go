@@ -0,0 +1,163 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA makes a CA, writes its certificate to a PEM file and returns that
+// file with a certificate it signed for 127.0.0.1.
+func testCA(t *testing.T) (string, tls.Certificate) {
+	t.Helper()
+
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	caKey := newKey()
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tictacgo test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ca, err = x509.ParseCertificate(caDER); err != nil {
+		t.Fatal(err)
+	}
+
+	key := newKey()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return caFile, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	caFile, cert := testCA(t)
+
+	s := NewServer()
+	s.HeartbeatInterval = 0
+	s.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	startTestServer(t, s)
+
+	wrongPin := strings.Repeat("AB:", 31) + "AB"
+	tests := []struct {
+		name    string
+		caFile  string
+		pin     string
+		connect bool
+	}{
+		{"CA file", caFile, "", true},
+		{"pin", "", Fingerprint(cert.Leaf), true},
+		{"pin without colons", "", strings.ReplaceAll(Fingerprint(cert.Leaf), ":", ""), true},
+		{"wrong pin", "", wrongPin, false},
+		{"wrong pin with CA file", caFile, wrongPin, false},
+		{"system CAs", "", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := ClientTLSConfig(test.caFile, test.pin)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := NewClient()
+			c.SetTLSConfig(config)
+			err = c.EstablishConnection(s.TCPAddr)
+			if err == nil {
+				c.CloseConnection()
+			}
+			if connected := err == nil; connected != test.connect {
+				t.Errorf("game connection made = %t, want %t: %v", connected, test.connect, err)
+			}
+
+			client := http.Client{
+				Transport: &http.Transport{TLSClientConfig: config},
+				Timeout:   5 * time.Second,
+			}
+			res, err := client.Get("https://" + s.RESTAddr + "/rooms")
+			if err == nil {
+				res.Body.Close()
+				if res.StatusCode != http.StatusOK {
+					t.Errorf("GET /rooms = %d", res.StatusCode)
+				}
+			}
+			if connected := err == nil; connected != test.connect {
+				t.Errorf("REST request made = %t, want %t: %v", connected, test.connect, err)
+			}
+		})
+	}
+
+	if _, err := ClientTLSConfig("", "not a fingerprint"); err == nil {
+		t.Error("ClientTLSConfig took a malformed pin")
+	}
+	if _, err := ClientTLSConfig(filepath.Join(t.TempDir(), "missing.pem"), ""); err == nil {
+		t.Error("ClientTLSConfig took a missing CA file")
+	}
+}
+
+func TestSelfSignedCertificatePinned(t *testing.T) {
+	cert, err := SelfSignedCertificate("localhost", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer()
+	s.HeartbeatInterval = 0
+	s.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	startTestServer(t, s)
+
+	config, err := ClientTLSConfig("", Fingerprint(cert.Leaf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient()
+	c.SetTLSConfig(config)
+	if err := c.EstablishConnection(s.TCPAddr); err != nil {
+		t.Fatalf("pinned self-signed certificate refused: %v", err)
+	}
+	c.CloseConnection()
+}
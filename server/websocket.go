@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultWebSocketPingInterval = 20 * time.Second
+	DefaultWebSocketPingTimeout  = 10 * time.Second
+
+	// DefaultMaxWebSocketMessage is the largest request a WebSocket client may
+	// send, in bytes. Every request fits in a fraction of this.
+	DefaultMaxWebSocketMessage = 16 << 10
+)
+
+// wsConn carries the game protocol over a WebSocket, one JSON message per text
+// frame, so the rest of the server can treat it like a TCP connection.
+type wsConn struct {
+	net.Conn
+	remoteAddr net.Addr
+}
+
+func (c wsConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+type wsAddr string
+
+func (a wsAddr) Network() string { return "websocket" }
+func (a wsAddr) String() string  { return string(a) }
+
+// getWebSocket upgrades the request and plays the same protocol as the TCP
+// listener, so WebSocket and TCP players share rooms.
+func (s *Server) getWebSocket(w http.ResponseWriter, r *http.Request) {
+	log.Info().Str("address", r.RemoteAddr).Msg("GET /ws request")
+
+	c, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		OriginPatterns: s.WebSocketOrigins,
+	})
+	if err != nil {
+		log.Err(err).Str("address", r.RemoteAddr).Msg("Failed to accept WebSocket")
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	conn := wsConn{
+		Conn:       websocket.NetConn(ctx, c, websocket.MessageText),
+		remoteAddr: wsAddr(r.RemoteAddr),
+	}
+	c.SetReadLimit(s.MaxWebSocketMessage) // after NetConn, which lifts the limit
+	go s.pingWebSocket(ctx, c, conn)
+
+	s.handleConnection(conn)
+}
+
+// pingWebSocket closes conn if a ping isn't answered within
+// WebSocketPingTimeout. Pongs are read by handleConnection's reads.
+func (s *Server) pingWebSocket(ctx context.Context, c *websocket.Conn, conn net.Conn) {
+	ticker := time.NewTicker(s.WebSocketPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, s.WebSocketPingTimeout)
+		err := c.Ping(pingCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Info().Err(err).Str("address", conn.RemoteAddr().String()).Msg("WebSocket missed a ping")
+				conn.Close()
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,523 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/tylerolson/tictacgo/server"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix starts the name of every environment variable the server reads,
+// like TICTACGO_REST_ADDR.
+const envPrefix = "TICTACGO_"
+
+// minTokenSecret is the fewest bytes a configured token secret can have.
+const minTokenSecret = 32
+
+// Config is everything the server can be set up with. It's read from a YAML
+// file, then environment variables, then flags, each overriding the last.
+type Config struct {
+	Listen    ListenConfig    `yaml:"listen" json:"listen"`
+	TLS       TLSConfig       `yaml:"tls" json:"tls"`
+	Log       LogConfig       `yaml:"log" json:"log"`
+	Storage   StorageConfig   `yaml:"storage" json:"storage"`
+	Snapshot  SnapshotConfig  `yaml:"snapshot" json:"snapshot"`
+	Rooms     RoomsConfig     `yaml:"rooms" json:"rooms"`
+	Heartbeat HeartbeatConfig `yaml:"heartbeat" json:"heartbeat"`
+	Limits    LimitsConfig    `yaml:"limits" json:"limits"`
+	Auth      AuthConfig      `yaml:"auth" json:"auth"`
+	Seed      []SeedRoom      `yaml:"seed" json:"seed"`
+
+	// ShutdownTimeout is how long to wait for connections to finish on
+	// SIGINT or SIGTERM.
+	ShutdownTimeout Duration `yaml:"shutdownTimeout" json:"shutdownTimeout"`
+}
+
+type ListenConfig struct {
+	TCP  string `yaml:"tcp" json:"tcp"`
+	REST string `yaml:"rest" json:"rest"`
+}
+
+// TLSConfig serves both listeners over TLS when Cert and Key are set, or
+// with a certificate generated at startup when SelfSigned is.
+type TLSConfig struct {
+	Cert       string `yaml:"cert" json:"cert"`
+	Key        string `yaml:"key" json:"key"`
+	SelfSigned bool   `yaml:"selfSigned" json:"selfSigned"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" json:"level"`   // trace, debug, info, warn or error
+	Format string `yaml:"format" json:"format"` // console or json
+}
+
+type StorageConfig struct {
+	Backend string `yaml:"backend" json:"backend"` // memory or bolt, bolt if only a path is given
+	Path    string `yaml:"path" json:"path"`
+}
+
+type SnapshotConfig struct {
+	Path     string   `yaml:"path" json:"path"` // no snapshots if empty
+	Interval Duration `yaml:"interval" json:"interval"`
+}
+
+type RoomsConfig struct {
+	Max                int      `yaml:"max" json:"max"`                   // 0 means no cap
+	MaxPerClient       int      `yaml:"maxPerClient" json:"maxPerClient"` // per account or IP address, 0 means no cap
+	IdleTTL            Duration `yaml:"idleTTL" json:"idleTTL"`
+	FinishedTTL        Duration `yaml:"finishedTTL" json:"finishedTTL"`
+	SeatGracePeriod    Duration `yaml:"seatGracePeriod" json:"seatGracePeriod"`
+	DefaultTimeControl string   `yaml:"defaultTimeControl" json:"defaultTimeControl"`
+}
+
+// HeartbeatConfig drops game connections that stop answering pings, holding
+// their seats. An Interval of 0 turns heartbeats off.
+type HeartbeatConfig struct {
+	Interval  Duration `yaml:"interval" json:"interval"`
+	MaxMissed int      `yaml:"maxMissed" json:"maxMissed"`
+}
+
+// LimitsConfig protects the server from clients sending too much.
+type LimitsConfig struct {
+	// ConnectionRate and IPRate limit making rooms, joining rooms and making
+	// moves, each separately.
+	ConnectionRate RateConfig `yaml:"connectionRate" json:"connectionRate"`
+	IPRate         RateConfig `yaml:"ipRate" json:"ipRate"`
+	MaxMessageSize int        `yaml:"maxMessageSize" json:"maxMessageSize"` // bytes, 0 means no cap
+
+	// How long REST clients get to send headers and bodies, and how long idle
+	// connections stay open. 0 means no timeout.
+	ReadHeaderTimeout Duration `yaml:"readHeaderTimeout" json:"readHeaderTimeout"`
+	BodyTimeout       Duration `yaml:"bodyTimeout" json:"bodyTimeout"`
+	IdleTimeout       Duration `yaml:"idleTimeout" json:"idleTimeout"`
+}
+
+// RateConfig allows Burst requests at once, refilled at Rate a second. A
+// Rate of 0 means no limit.
+type RateConfig struct {
+	Rate  float64 `yaml:"rate" json:"rate"`
+	Burst int     `yaml:"burst" json:"burst"`
+}
+
+// AuthConfig's TokenSecret, or the contents of TokenSecretFile, signs login
+// tokens. Without either a random one is made at startup and every login
+// ends when the server restarts.
+type AuthConfig struct {
+	Required        bool     `yaml:"required" json:"required"`
+	TokenTTL        Duration `yaml:"tokenTTL" json:"tokenTTL"`
+	TokenSecret     string   `yaml:"tokenSecret" json:"-"` // never logged
+	TokenSecretFile string   `yaml:"tokenSecretFile" json:"tokenSecretFile,omitempty"`
+	// Admins are the accounts that can close any room.
+	Admins []string `yaml:"admins" json:"admins,omitempty"`
+}
+
+// SeedRoom is a room made when the server starts, if it isn't open already.
+type SeedRoom struct {
+	Name             string `yaml:"name" json:"name"`
+	Ranked           bool   `yaml:"ranked" json:"ranked,omitempty"`
+	DisableTakebacks bool   `yaml:"disableTakebacks" json:"disableTakebacks,omitempty"`
+	TimeControl      string `yaml:"timeControl" json:"timeControl,omitempty"`
+}
+
+// Duration is a time.Duration written like "30s" or "5m" in config files.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func defaultConfig() Config {
+	return Config{
+		Listen: ListenConfig{
+			TCP:  server.DefaultTCPAddr,
+			REST: server.DefaultRESTAddr,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "console",
+		},
+		Snapshot: SnapshotConfig{
+			Interval: Duration(server.DefaultSnapshotInterval),
+		},
+		Rooms: RoomsConfig{
+			IdleTTL:         Duration(server.DefaultRoomIdleTTL),
+			FinishedTTL:     Duration(server.DefaultFinishedRoomTTL),
+			SeatGracePeriod: Duration(server.DefaultSeatGracePeriod),
+			MaxPerClient:    server.DefaultMaxRoomsPerClient,
+		},
+		Limits: LimitsConfig{
+			ConnectionRate: RateConfig{
+				Rate:  server.DefaultConnectionRateLimit.Rate,
+				Burst: server.DefaultConnectionRateLimit.Burst,
+			},
+			IPRate: RateConfig{
+				Rate:  server.DefaultIPRateLimit.Rate,
+				Burst: server.DefaultIPRateLimit.Burst,
+			},
+			MaxMessageSize:    server.DefaultMaxMessageSize,
+			ReadHeaderTimeout: Duration(server.DefaultRESTReadHeaderTimeout),
+			BodyTimeout:       Duration(server.DefaultRESTBodyTimeout),
+			IdleTimeout:       Duration(server.DefaultRESTIdleTimeout),
+		},
+		Heartbeat: HeartbeatConfig{
+			Interval:  Duration(server.DefaultHeartbeatInterval),
+			MaxMissed: server.DefaultMaxMissedHeartbeats,
+		},
+		Auth: AuthConfig{
+			TokenTTL: Duration(server.DefaultTokenTTL),
+		},
+		ShutdownTimeout: Duration(server.DefaultShutdownTimeout),
+	}
+}
+
+// setting is a config value that can also be set by a flag and an
+// environment variable.
+type setting struct {
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+	bool  bool // flag can be given without a value
+}
+
+// env is the environment variable for the setting, the flag name in upper
+// case with dashes turned into underscores.
+func (s setting) env() string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(s.flag, "-", "_"))
+}
+
+func stringSetting(flag string, usage string, field func(c *Config) *string) setting {
+	return setting{flag: flag, usage: usage, set: func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}}
+}
+
+func durationSetting(flag string, usage string, field func(c *Config) *Duration) setting {
+	return setting{flag: flag, usage: usage, set: func(c *Config, value string) error {
+		return field(c).UnmarshalText([]byte(value))
+	}}
+}
+
+func intSetting(flag string, usage string, field func(c *Config) *int) setting {
+	return setting{flag: flag, usage: usage, set: func(c *Config, value string) (err error) {
+		*field(c), err = strconv.Atoi(value)
+		return err
+	}}
+}
+
+func floatSetting(flag string, usage string, field func(c *Config) *float64) setting {
+	return setting{flag: flag, usage: usage, set: func(c *Config, value string) (err error) {
+		*field(c), err = strconv.ParseFloat(value, 64)
+		return err
+	}}
+}
+
+func boolSetting(flag string, usage string, field func(c *Config) *bool) setting {
+	return setting{flag: flag, usage: usage, bool: true, set: func(c *Config, value string) (err error) {
+		*field(c), err = strconv.ParseBool(value)
+		return err
+	}}
+}
+
+var settings = []setting{
+	stringSetting("tcp-addr", "address the game server listens on", func(c *Config) *string { return &c.Listen.TCP }),
+	stringSetting("rest-addr", "address the REST server listens on", func(c *Config) *string { return &c.Listen.REST }),
+	stringSetting("tls-cert", "TLS certificate file, serves both listeners over TLS with -tls-key", func(c *Config) *string { return &c.TLS.Cert }),
+	stringSetting("tls-key", "TLS private key file", func(c *Config) *string { return &c.TLS.Key }),
+	boolSetting("tls-self-signed", "serve TLS with a certificate generated at startup, for development", func(c *Config) *bool { return &c.TLS.SelfSigned }),
+	stringSetting("log-level", "trace, debug, info, warn or error", func(c *Config) *string { return &c.Log.Level }),
+	stringSetting("log-format", "console or json", func(c *Config) *string { return &c.Log.Format }),
+	stringSetting("storage", "memory or bolt", func(c *Config) *string { return &c.Storage.Backend }),
+	stringSetting("db", "bbolt database file, everything is kept in memory if empty", func(c *Config) *string { return &c.Storage.Path }),
+	stringSetting("snapshot", "file to keep live games in across restarts", func(c *Config) *string { return &c.Snapshot.Path }),
+	durationSetting("snapshot-interval", "how often to write the snapshot", func(c *Config) *Duration { return &c.Snapshot.Interval }),
+	intSetting("max-rooms", "most rooms open at once, 0 for no cap", func(c *Config) *int { return &c.Rooms.Max }),
+	intSetting("max-rooms-per-client", "most rooms one account or IP address can have open, 0 for no cap", func(c *Config) *int { return &c.Rooms.MaxPerClient }),
+	durationSetting("room-idle-ttl", "how long an empty room stays open", func(c *Config) *Duration { return &c.Rooms.IdleTTL }),
+	durationSetting("finished-room-ttl", "how long a room stays open after its game ends", func(c *Config) *Duration { return &c.Rooms.FinishedTTL }),
+	durationSetting("seat-grace-period", "how long a dropped player's seat is held", func(c *Config) *Duration { return &c.Rooms.SeatGracePeriod }),
+	stringSetting("default-time-control", "time control for rooms that don't pick one, like 3+2", func(c *Config) *string { return &c.Rooms.DefaultTimeControl }),
+	durationSetting("heartbeat-interval", "how often to ping game connections, 0 for never", func(c *Config) *Duration { return &c.Heartbeat.Interval }),
+	intSetting("max-missed-heartbeats", "how many pings can go unanswered before a connection is dropped", func(c *Config) *int { return &c.Heartbeat.MaxMissed }),
+	floatSetting("connection-rate", "moves, joins and new rooms a second per connection, 0 for no limit", func(c *Config) *float64 { return &c.Limits.ConnectionRate.Rate }),
+	intSetting("connection-burst", "moves, joins and new rooms a connection can make at once", func(c *Config) *int { return &c.Limits.ConnectionRate.Burst }),
+	floatSetting("ip-rate", "moves, joins and new rooms a second per IP address, 0 for no limit", func(c *Config) *float64 { return &c.Limits.IPRate.Rate }),
+	intSetting("ip-burst", "moves, joins and new rooms an IP address can make at once", func(c *Config) *int { return &c.Limits.IPRate.Burst }),
+	intSetting("max-message-size", "largest game request or REST body in bytes, 0 for no cap", func(c *Config) *int { return &c.Limits.MaxMessageSize }),
+	durationSetting("read-header-timeout", "how long REST clients get to send headers, 0 for no timeout", func(c *Config) *Duration { return &c.Limits.ReadHeaderTimeout }),
+	durationSetting("body-timeout", "how long REST clients get to send a body, 0 for no timeout", func(c *Config) *Duration { return &c.Limits.BodyTimeout }),
+	durationSetting("idle-timeout", "how long idle REST connections stay open, 0 for no timeout", func(c *Config) *Duration { return &c.Limits.IdleTimeout }),
+	boolSetting("require-auth", "only let logged in players play", func(c *Config) *bool { return &c.Auth.Required }),
+	durationSetting("token-ttl", "how long a login lasts", func(c *Config) *Duration { return &c.Auth.TokenTTL }),
+	stringSetting("token-secret", "secret login tokens are signed with, at least 32 bytes, random at every start if empty", func(c *Config) *string { return &c.Auth.TokenSecret }),
+	stringSetting("token-secret-file", "file holding the secret login tokens are signed with", func(c *Config) *string { return &c.Auth.TokenSecretFile }),
+	{
+		flag:  "admins",
+		usage: "comma separated accounts that can close any room",
+		set: func(c *Config, value string) error {
+			c.Auth.Admins = nil
+			for _, name := range strings.Split(value, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					c.Auth.Admins = append(c.Auth.Admins, name)
+				}
+			}
+			return nil
+		},
+	},
+	durationSetting("shutdown-timeout", "how long to wait for connections to finish when stopping", func(c *Config) *Duration { return &c.ShutdownTimeout }),
+	{
+		flag:  "seed",
+		usage: "comma separated rooms to open at startup, replacing the config file's",
+		set: func(c *Config, value string) error {
+			c.Seed = nil
+			for _, name := range strings.Split(value, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					c.Seed = append(c.Seed, SeedRoom{Name: name})
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// flagValue holds a flag's raw value until the config file and environment
+// have been read, so flags can override both.
+type flagValue struct {
+	value  string
+	isBool bool
+}
+
+func (f *flagValue) String() string {
+	return f.value
+}
+
+func (f *flagValue) Set(value string) error {
+	f.value = value
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// loadConfig builds the config from the file named by -config or
+// TICTACGO_CONFIG, the environment and args.
+func loadConfig(args []string) (Config, error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(envPrefix+"CONFIG"), "YAML config file, env "+envPrefix+"CONFIG")
+	values := make(map[string]*flagValue, len(settings))
+	for _, s := range settings {
+		values[s.flag] = &flagValue{isBool: s.bool}
+		fs.Var(values[s.flag], s.flag, s.usage+", env "+s.env())
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	config := defaultConfig()
+	if *configPath != "" {
+		data, err := os.ReadFile(*configPath)
+		if err != nil {
+			return config, fmt.Errorf("couldn't read config file\n%w", err)
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+			return config, fmt.Errorf("config file %s is invalid\n%w", *configPath, err)
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env()); ok {
+			if err := s.set(&config, value); err != nil {
+				return config, fmt.Errorf("%s=%q is invalid\n%w", s.env(), value, err)
+			}
+		}
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		if s, ok := settingByFlag(f.Name); ok && err == nil {
+			if setErr := s.set(&config, values[f.Name].value); setErr != nil {
+				err = fmt.Errorf("-%s=%q is invalid\n%w", f.Name, values[f.Name].value, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return config, err
+	}
+
+	if config.Storage.Backend == "" {
+		config.Storage.Backend = "memory"
+		if config.Storage.Path != "" {
+			config.Storage.Backend = "bolt"
+		}
+	}
+
+	return config, config.validate()
+}
+
+func settingByFlag(name string) (setting, bool) {
+	for _, s := range settings {
+		if s.flag == name {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// validate reports every problem with the config at once.
+func (c Config) validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if _, port, err := net.SplitHostPort(c.Listen.TCP); err != nil || port == "" {
+		fail("listen.tcp: %q should look like host:port or :port", c.Listen.TCP)
+	}
+	if _, port, err := net.SplitHostPort(c.Listen.REST); err != nil || port == "" {
+		fail("listen.rest: %q should look like host:port or :port", c.Listen.REST)
+	}
+	if c.Listen.TCP == c.Listen.REST {
+		fail("listen.tcp and listen.rest can't both be %q", c.Listen.TCP)
+	}
+
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		fail("tls.cert and tls.key have to be set together")
+	}
+	if c.TLS.SelfSigned && c.TLS.Cert != "" {
+		fail("tls.selfSigned can't be used with tls.cert and tls.key")
+	}
+
+	if _, err := zerolog.ParseLevel(c.Log.Level); err != nil || c.Log.Level == "" {
+		fail("log.level: %q isn't one of trace, debug, info, warn or error", c.Log.Level)
+	}
+	if c.Log.Format != "console" && c.Log.Format != "json" {
+		fail("log.format: %q isn't console or json", c.Log.Format)
+	}
+
+	switch c.Storage.Backend {
+	case "memory":
+		if c.Storage.Path != "" {
+			fail("storage.path is only used by the bolt backend")
+		}
+	case "bolt":
+		if c.Storage.Path == "" {
+			fail("storage.path is needed for the bolt backend")
+		}
+	default:
+		fail("storage.backend: %q isn't memory or bolt", c.Storage.Backend)
+	}
+
+	positive := []struct {
+		name string
+		d    Duration
+	}{
+		{"snapshot.interval", c.Snapshot.Interval},
+		{"rooms.idleTTL", c.Rooms.IdleTTL},
+		{"rooms.finishedTTL", c.Rooms.FinishedTTL},
+		{"rooms.seatGracePeriod", c.Rooms.SeatGracePeriod},
+		{"auth.tokenTTL", c.Auth.TokenTTL},
+		{"shutdownTimeout", c.ShutdownTimeout},
+	}
+	for _, p := range positive {
+		if p.d <= 0 {
+			fail("%s has to be more than 0", p.name)
+		}
+	}
+
+	if c.Rooms.Max < 0 {
+		fail("rooms.max can't be negative")
+	} else if c.Rooms.Max > 0 && len(c.Seed) > c.Rooms.Max {
+		fail("seeding %d rooms is more than rooms.max (%d)", len(c.Seed), c.Rooms.Max)
+	}
+	if c.Rooms.MaxPerClient < 0 {
+		fail("rooms.maxPerClient can't be negative")
+	}
+	if _, err := server.ParseTimeControl(c.Rooms.DefaultTimeControl); err != nil {
+		fail("rooms.defaultTimeControl: %w", err)
+	}
+
+	rates := []struct {
+		name string
+		rate RateConfig
+	}{
+		{"limits.connectionRate", c.Limits.ConnectionRate},
+		{"limits.ipRate", c.Limits.IPRate},
+	}
+	for _, r := range rates {
+		if r.rate.Rate < 0 {
+			fail("%s.rate can't be negative", r.name)
+		} else if r.rate.Rate > 0 && r.rate.Burst < 1 {
+			fail("%s.burst has to be at least 1", r.name)
+		}
+	}
+	if c.Limits.MaxMessageSize < 0 {
+		fail("limits.maxMessageSize can't be negative")
+	}
+	timeouts := []struct {
+		name string
+		d    Duration
+	}{
+		{"limits.readHeaderTimeout", c.Limits.ReadHeaderTimeout},
+		{"limits.bodyTimeout", c.Limits.BodyTimeout},
+		{"limits.idleTimeout", c.Limits.IdleTimeout},
+	}
+	for _, t := range timeouts {
+		if t.d < 0 {
+			fail("%s can't be negative", t.name)
+		}
+	}
+
+	if c.Auth.TokenSecret != "" && c.Auth.TokenSecretFile != "" {
+		fail("auth.tokenSecret and auth.tokenSecretFile can't both be set")
+	}
+	if c.Auth.TokenSecret != "" && len(c.Auth.TokenSecret) < minTokenSecret {
+		fail("auth.tokenSecret has to be at least %d bytes", minTokenSecret)
+	}
+
+	if c.Heartbeat.Interval < 0 {
+		fail("heartbeat.interval can't be negative")
+	}
+	if c.Heartbeat.MaxMissed < 1 {
+		fail("heartbeat.maxMissed has to be at least 1")
+	}
+
+	seen := make(map[string]bool, len(c.Seed))
+	for i, room := range c.Seed {
+		switch {
+		case !server.ValidRoomName(room.Name):
+			fail("seed[%d]: %q isn't a valid room name, %w", i, room.Name, server.ErrInvalidRoomName)
+		case seen[room.Name]:
+			fail("seed[%d]: %q is seeded twice", i, room.Name)
+		}
+		seen[room.Name] = true
+
+		if _, err := server.ParseTimeControl(room.TimeControl); err != nil {
+			fail("seed[%d].timeControl: %w", i, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -1,22 +1,154 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/tylerolson/tictacgo/server"
+	"github.com/tylerolson/tictacgo/store"
 )
 
 func main() {
+	config, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config:\n%v\n", err)
+		os.Exit(2)
+	}
+
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	level, _ := zerolog.ParseLevel(config.Log.Level)
+	zerolog.SetGlobalLevel(level)
+	if config.Log.Format == "console" {
+		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	}
+	log.Info().Interface("config", config).Msg("Loaded config")
+
+	var st store.Store = store.NewMemory()
+	if config.Storage.Backend == "bolt" {
+		bolt, err := store.OpenBolt(config.Storage.Path)
+		if err != nil {
+			log.Fatal().Err(err).Str("path", config.Storage.Path).Msg("Failed to open store")
+		}
+		st = bolt
+	}
+
+	s, err := server.NewServerWithStore(st)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to start server")
+	}
+	defer s.Close()
+
+	if err := configure(s, config); err != nil {
+		log.Fatal().Err(err).Msg("Failed to configure server")
+	}
+
+	for _, seed := range config.Seed {
+		timeControl, _ := server.ParseTimeControl(seed.TimeControl) // checked by validate
+		options := server.RoomOptions{
+			Ranked:           seed.Ranked,
+			DisableTakebacks: seed.DisableTakebacks,
+			TimeControl:      timeControl,
+			Permanent:        true,
+		}
+		if _, err := s.MakeRoom(seed.Name, options); err != nil && !errors.Is(err, server.ErrRoomExists) {
+			log.Fatal().Err(err).Str("name", seed.Name).Msg("Failed to seed room")
+		}
+	}
+	if path := config.Snapshot.Path; path != "" {
+		if err := s.RestoreSnapshot(path); err != nil {
+			log.Fatal().Err(err).Str("path", path).Msg("Failed to restore snapshot")
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := s.Start(ctx); err != nil {
+		log.Fatal().Err(err).Msg("Failed to start server")
+	}
+	<-s.Done()
+}
+
+// configure applies everything in config that's set on the server itself.
+func configure(s *server.Server, config Config) error {
+	s.TCPAddr = config.Listen.TCP
+	s.RESTAddr = config.Listen.REST
+	s.MaxRooms = config.Rooms.Max
+	s.MaxRoomsPerClient = config.Rooms.MaxPerClient
+	s.RoomIdleTTL = time.Duration(config.Rooms.IdleTTL)
+	s.FinishedRoomTTL = time.Duration(config.Rooms.FinishedTTL)
+	s.SeatGracePeriod = time.Duration(config.Rooms.SeatGracePeriod)
+	s.SnapshotInterval = time.Duration(config.Snapshot.Interval)
+	s.SnapshotPath = config.Snapshot.Path
+	s.ShutdownTimeout = time.Duration(config.ShutdownTimeout)
+	s.ConnectionRateLimit = server.RateLimit(config.Limits.ConnectionRate)
+	s.IPRateLimit = server.RateLimit(config.Limits.IPRate)
+	s.MaxMessageSize = int64(config.Limits.MaxMessageSize)
+	s.RESTReadHeaderTimeout = time.Duration(config.Limits.ReadHeaderTimeout)
+	s.RESTBodyTimeout = time.Duration(config.Limits.BodyTimeout)
+	s.RESTIdleTimeout = time.Duration(config.Limits.IdleTimeout)
+	s.HeartbeatInterval = time.Duration(config.Heartbeat.Interval)
+	s.MaxMissedHeartbeats = config.Heartbeat.MaxMissed
+	s.RequireAuth = config.Auth.Required
+	s.TokenTTL = time.Duration(config.Auth.TokenTTL)
+	s.Admins = config.Auth.Admins
+
+	secret := config.Auth.TokenSecret
+	if config.Auth.TokenSecretFile != "" {
+		data, err := os.ReadFile(config.Auth.TokenSecretFile)
+		if err != nil {
+			return fmt.Errorf("couldn't read token secret\n%w", err)
+		}
+		if secret = strings.TrimSpace(string(data)); len(secret) < minTokenSecret {
+			return fmt.Errorf("token secret in %s has to be at least %d bytes", config.Auth.TokenSecretFile, minTokenSecret)
+		}
+	}
+	if secret != "" {
+		s.TokenSecret = []byte(secret)
+	} else {
+		log.Warn().Msg("No auth.tokenSecret set, logins won't survive a restart")
+	}
 
-	s := server.NewServer()
+	var err error
+	if s.DefaultTimeControl, err = server.ParseTimeControl(config.Rooms.DefaultTimeControl); err != nil {
+		return err
+	}
 
-	s.MakeRoom("test13")
-	s.MakeRoom("yo")
-	go s.StartTCPServer()
-	s.StartRESTServer()
+	var cert tls.Certificate
+	switch {
+	case config.TLS.Cert != "":
+		if cert, err = tls.LoadX509KeyPair(config.TLS.Cert, config.TLS.Key); err != nil {
+			return fmt.Errorf("couldn't load TLS certificate\n%w", err)
+		}
+	case config.TLS.SelfSigned:
+		hosts := []string{"localhost", "127.0.0.1", "::1"}
+		if hostname, err := os.Hostname(); err == nil {
+			hosts = append(hosts, hostname)
+		}
+		if cert, err = server.SelfSignedCertificate(hosts...); err != nil {
+			return fmt.Errorf("couldn't generate TLS certificate\n%w", err)
+		}
+		log.Warn().Str("fingerprint", server.Fingerprint(cert.Leaf)).Strs("hosts", hosts).
+			Msg("Using a self-signed certificate, clients have to pin its fingerprint")
+	default:
+		return nil
+	}
+	s.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
 
+	return nil
 }
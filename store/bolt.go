@@ -0,0 +1,190 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	metaBucket  = []byte("meta")
+	usersBucket = []byte("users")
+	gamesBucket = []byte("games")
+	roomsBucket = []byte("rooms")
+
+	schemaVersionKey = []byte("schema_version")
+)
+
+// migrations bring the file up to date, migrations[i] moves it from schema
+// version i to i+1. Only ever append to this list.
+var migrations = []func(tx *bolt.Tx) error{
+	func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{usersBucket, gamesBucket, roomsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// Bolt stores everything as JSON in a single bbolt file.
+type Bolt struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens or creates the file at path and runs any pending migrations.
+func OpenBolt(path string) (*Bolt, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open bolt store\n%w", err)
+	}
+
+	b := &Bolt{db: db}
+	if err := b.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *Bolt) migrate() error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+
+		version := 0
+		if v := meta.Get(schemaVersionKey); v != nil {
+			version = int(binary.BigEndian.Uint64(v))
+		}
+		if version > len(migrations) {
+			return fmt.Errorf("store schema version %d is newer than this server understands (%d)", version, len(migrations))
+		}
+
+		for ; version < len(migrations); version++ {
+			if err := migrations[version](tx); err != nil {
+				return fmt.Errorf("migration %d failed\n%w", version+1, err)
+			}
+		}
+
+		return meta.Put(schemaVersionKey, binary.BigEndian.AppendUint64(nil, uint64(version)))
+	})
+}
+
+func (b *Bolt) CreateUser(user User) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(strings.ToLower(user.Username))
+		if tx.Bucket(usersBucket).Get(key) != nil {
+			return ErrExists
+		}
+		return put(tx.Bucket(usersBucket), key, user)
+	})
+}
+
+func (b *Bolt) GetUser(username string) (User, error) {
+	var user User
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(usersBucket), []byte(strings.ToLower(username)), &user)
+	})
+	return user, err
+}
+
+func (b *Bolt) UpdateUser(user User) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(strings.ToLower(user.Username))
+		if tx.Bucket(usersBucket).Get(key) == nil {
+			return ErrNotFound
+		}
+		return put(tx.Bucket(usersBucket), key, user)
+	})
+}
+
+func (b *Bolt) ListUsers() ([]User, error) {
+	return list[User](b.db, usersBucket)
+}
+
+func (b *Bolt) SaveGame(game GameRecord) (string, error) {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(gamesBucket)
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		game.ID = strconv.FormatUint(id, 10)
+		return put(bucket, []byte(game.ID), game)
+	})
+	return game.ID, err
+}
+
+func (b *Bolt) GetGame(id string) (GameRecord, error) {
+	var game GameRecord
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(gamesBucket), []byte(id), &game)
+	})
+	return game, err
+}
+
+func (b *Bolt) ListGames() ([]GameRecord, error) {
+	return list[GameRecord](b.db, gamesBucket)
+}
+
+func (b *Bolt) SaveRoom(room RoomRecord) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return put(tx.Bucket(roomsBucket), []byte(room.Name), room)
+	})
+}
+
+func (b *Bolt) DeleteRoom(name string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(roomsBucket).Delete([]byte(name))
+	})
+}
+
+func (b *Bolt) ListRooms() ([]RoomRecord, error) {
+	return list[RoomRecord](b.db, roomsBucket)
+}
+
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
+
+func put(bucket *bolt.Bucket, key []byte, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, data)
+}
+
+func get(bucket *bolt.Bucket, key []byte, value any) error {
+	data := bucket.Get(key)
+	if data == nil {
+		return ErrNotFound
+	}
+	return json.Unmarshal(data, value)
+}
+
+func list[T any](db *bolt.DB, bucket []byte) ([]T, error) {
+	values := make([]T, 0)
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(_, data []byte) error {
+			var value T
+			if err := json.Unmarshal(data, &value); err != nil {
+				return errors.Join(fmt.Errorf("corrupt record in %s", bucket), err)
+			}
+			values = append(values, value)
+			return nil
+		})
+	})
+	return values, err
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Memory keeps everything in maps, it is meant for tests and throwaway servers.
+type Memory struct {
+	mu     sync.Mutex
+	users  map[string]User
+	games  map[string]GameRecord
+	rooms  map[string]RoomRecord
+	gameID int
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		users: make(map[string]User),
+		games: make(map[string]GameRecord),
+		rooms: make(map[string]RoomRecord),
+	}
+}
+
+func (m *Memory) CreateUser(user User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := strings.ToLower(user.Username)
+	if _, ok := m.users[key]; ok {
+		return ErrExists
+	}
+	m.users[key] = user
+	return nil
+}
+
+func (m *Memory) GetUser(username string) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[strings.ToLower(username)]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	user.History = slices.Clone(user.History)
+	return user, nil
+}
+
+func (m *Memory) UpdateUser(user User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := strings.ToLower(user.Username)
+	if _, ok := m.users[key]; !ok {
+		return ErrNotFound
+	}
+	m.users[key] = user
+	return nil
+}
+
+func (m *Memory) ListUsers() ([]User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	users := make([]User, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (m *Memory) SaveGame(game GameRecord) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.gameID++
+	game.ID = strconv.Itoa(m.gameID)
+	m.games[game.ID] = game
+	return game.ID, nil
+}
+
+func (m *Memory) GetGame(id string) (GameRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	game, ok := m.games[id]
+	if !ok {
+		return GameRecord{}, ErrNotFound
+	}
+	return game, nil
+}
+
+func (m *Memory) ListGames() ([]GameRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	games := make([]GameRecord, 0, len(m.games))
+	for _, game := range m.games {
+		games = append(games, game)
+	}
+	return games, nil
+}
+
+func (m *Memory) SaveRoom(room RoomRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rooms[room.Name] = room
+	return nil
+}
+
+func (m *Memory) DeleteRoom(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.rooms, name)
+	return nil
+}
+
+func (m *Memory) ListRooms() ([]RoomRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rooms := make([]RoomRecord, 0, len(m.rooms))
+	for _, room := range m.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms, nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
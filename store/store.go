@@ -0,0 +1,79 @@
+// Package store persists accounts, finished games and open rooms.
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tylerolson/tictacgo"
+	"github.com/tylerolson/tictacgo/rating"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrExists   = errors.New("already exists")
+)
+
+// Store is implemented by every storage backend. Usernames are matched case
+// insensitively.
+type Store interface {
+	CreateUser(user User) error
+	GetUser(username string) (User, error)
+	UpdateUser(user User) error
+	ListUsers() ([]User, error)
+
+	// SaveGame stores a finished game and returns the ID it was given.
+	SaveGame(game GameRecord) (string, error)
+	GetGame(id string) (GameRecord, error)
+	ListGames() ([]GameRecord, error)
+
+	SaveRoom(room RoomRecord) error
+	DeleteRoom(name string) error
+	ListRooms() ([]RoomRecord, error)
+
+	Close() error
+}
+
+type User struct {
+	Username     string         `json:"username"`
+	PasswordHash []byte         `json:"passwordHash"`
+	Created      time.Time      `json:"created"`
+	Rating       rating.Rating  `json:"rating"`
+	Games        int            `json:"games"` // rated games played
+	History      []RatingChange `json:"history"`
+}
+
+// RatingChange is one entry in a user's rating history.
+type RatingChange struct {
+	Room     string    `json:"room"`
+	Opponent string    `json:"opponent"`
+	Score    float64   `json:"score"`
+	Before   float64   `json:"before"`
+	After    float64   `json:"after"`
+	Time     time.Time `json:"time"`
+}
+
+// GameRecord is a finished game kept after its room has moved on.
+type GameRecord struct {
+	ID          string            `json:"id"`
+	Room        string            `json:"room"`
+	Variant     string            `json:"variant"`
+	TimeControl string            `json:"timeControl,omitempty"`
+	Players     map[string]string `json:"players"` // username of each mark, missing for guests
+	Game        tictacgo.Game     `json:"game"`
+	Finished    time.Time         `json:"finished"`
+}
+
+// RoomRecord is what is needed to bring an open room back after a restart.
+type RoomRecord struct {
+	Name             string    `json:"name"`
+	Ranked           bool      `json:"ranked"`
+	DisableTakebacks bool      `json:"disableTakebacks"`
+	TimeControl      string    `json:"timeControl"`
+	Private          bool      `json:"private"`
+	PasswordHash     []byte    `json:"passwordHash"`
+	InviteCode       string    `json:"inviteCode"`
+	Creator          string    `json:"creator,omitempty"`
+	Permanent        bool      `json:"permanent,omitempty"`
+	Created          time.Time `json:"created"`
+}
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tylerolson/tictacgo"
+	"github.com/tylerolson/tictacgo/rating"
+	bolt "go.etcd.io/bbolt"
+)
+
+// testStore checks st round-trips everything the Store interface keeps.
+func testStore(t *testing.T, st Store) {
+	t.Helper()
+
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	user := User{
+		Username:     "Alice",
+		PasswordHash: []byte("hash"),
+		Created:      created,
+		Rating:       rating.New(),
+	}
+	if err := st.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.CreateUser(User{Username: "alice"}); !errors.Is(err, ErrExists) {
+		t.Errorf("creating alice again = %v, want ErrExists", err)
+	}
+	if got, err := st.GetUser("ALICE"); err != nil || !reflect.DeepEqual(got, user) {
+		t.Errorf("GetUser = %+v, %v, want %+v", got, err, user)
+	}
+	if _, err := st.GetUser("bob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetUser(bob) = %v, want ErrNotFound", err)
+	}
+
+	user.Games = 1
+	user.History = []RatingChange{{Room: "r", Opponent: "bob", Score: 1, Before: 1500, After: 1662.31, Time: created}}
+	if err := st.UpdateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.UpdateUser(User{Username: "bob"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateUser(bob) = %v, want ErrNotFound", err)
+	}
+	if users, err := st.ListUsers(); err != nil || len(users) != 1 || !reflect.DeepEqual(users[0], user) {
+		t.Errorf("ListUsers = %+v, %v, want [%+v]", users, err, user)
+	}
+
+	game := tictacgo.NewGame()
+	for _, move := range []string{"1", "4", "2", "5", "3"} {
+		game.Move(move)
+	}
+	record := GameRecord{
+		Room:     "r",
+		Variant:  "classic",
+		Players:  map[string]string{"X": "Alice"},
+		Game:     *game,
+		Finished: created,
+	}
+	first, err := st.SaveGame(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := st.SaveGame(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("both games were given ID %q", first)
+	}
+	record.ID = first
+	if got, err := st.GetGame(first); err != nil || !reflect.DeepEqual(got, record) {
+		t.Errorf("GetGame = %+v, %v, want %+v", got, err, record)
+	}
+	if _, err := st.GetGame("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetGame(nope) = %v, want ErrNotFound", err)
+	}
+	if games, err := st.ListGames(); err != nil || len(games) != 2 {
+		t.Errorf("ListGames = %d games, %v, want 2", len(games), err)
+	}
+
+	room := RoomRecord{Name: "blitz", Ranked: true, TimeControl: "3+2", Permanent: true, Created: created}
+	if err := st.SaveRoom(room); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SaveRoom(RoomRecord{Name: "gone", Created: created}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.DeleteRoom("gone"); err != nil {
+		t.Fatal(err)
+	}
+	if rooms, err := st.ListRooms(); err != nil || len(rooms) != 1 || !reflect.DeepEqual(rooms[0], room) {
+		t.Errorf("ListRooms = %+v, %v, want [%+v]", rooms, err, room)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestBolt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tictacgo.db")
+	b, err := OpenBolt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, b)
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = OpenBolt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if users, err := b.ListUsers(); err != nil || len(users) != 1 {
+		t.Errorf("reopened store has %d users, %v, want 1", len(users), err)
+	}
+	if rooms, err := b.ListRooms(); err != nil || len(rooms) != 1 {
+		t.Errorf("reopened store has %d rooms, %v, want 1", len(rooms), err)
+	}
+}
+
+func TestBoltMigrations(t *testing.T) {
+	// setVersion makes a file at schema version, with nothing else in it.
+	setVersion := func(t *testing.T, version int) string {
+		t.Helper()
+
+		path := filepath.Join(t.TempDir(), "tictacgo.db")
+		db, err := bolt.Open(path, 0600, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		err = db.Update(func(tx *bolt.Tx) error {
+			meta, err := tx.CreateBucket(metaBucket)
+			if err != nil {
+				return err
+			}
+			return meta.Put(schemaVersionKey, binary.BigEndian.AppendUint64(nil, uint64(version)))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	b, err := OpenBolt(setVersion(t, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	err = b.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(metaBucket).Get(schemaVersionKey); int(binary.BigEndian.Uint64(v)) != len(migrations) {
+			t.Errorf("schema version %d after migrating, want %d", binary.BigEndian.Uint64(v), len(migrations))
+		}
+		for _, bucket := range [][]byte{usersBucket, gamesBucket, roomsBucket} {
+			if tx.Bucket(bucket) == nil {
+				t.Errorf("migrating didn't make the %s bucket", bucket)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenBolt(setVersion(t, len(migrations)+1)); err == nil {
+		t.Error("opened a store with a newer schema")
+	}
+}
@@ -6,18 +6,30 @@ import (
 )
 
 type Game struct {
-	Board  []string
-	Turn   string
-	Winner string
-	Moves  int
+	Board     []string
+	Turn      string
+	Winner    string
+	Moves     int
+	Reason    string // how the game ended when it wasn't decided on the board
+	History   []int  // board index of every move in order
+	Takebacks []Takeback
+}
+
+// Takeback records a move that was undone.
+type Takeback struct {
+	Player string
+	Cell   int
+	Ply    int
 }
 
 func NewGame() *Game {
 	return &Game{
-		Board:  []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"},
-		Turn:   "X",
-		Winner: "",
-		Moves:  0,
+		Board:     []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"},
+		Turn:      "X",
+		Winner:    "",
+		Moves:     0,
+		History:   []int{},
+		Takebacks: []Takeback{},
 	}
 }
 
@@ -26,12 +38,40 @@ func (g *Game) SetGame(game Game) {
 	g.Turn = game.Turn
 	g.Winner = game.Winner
 	g.Moves = game.Moves
+	g.Reason = game.Reason
+	g.History = game.History
+	g.Takebacks = game.Takebacks
 }
 
 func (g *Game) HasWinner() bool {
 	return g.Winner != ""
 }
 
+// Resign ends the game in favour of player's opponent.
+func (g *Game) Resign(player string) {
+	g.Winner = Opponent(player)
+	g.Reason = "resignation"
+}
+
+// AgreeDraw ends the game as a tie.
+func (g *Game) AgreeDraw() {
+	g.Winner = "tie"
+	g.Reason = "agreement"
+}
+
+// Timeout ends the game in favour of the opponent of player, who ran out of time.
+func (g *Game) Timeout(player string) {
+	g.Winner = Opponent(player)
+	g.Reason = "timeout"
+}
+
+func Opponent(player string) string {
+	if player == "X" {
+		return "O"
+	}
+	return "X"
+}
+
 func (g *Game) SetCell(cell int, value string) {
 	g.Board[cell] = value
 }
@@ -92,7 +132,7 @@ func (g *Game) CheckWinner() bool {
 }
 
 func (g *Game) Move(cell string) bool {
-	if g.CheckWinner() {
+	if g.HasWinner() || g.CheckWinner() {
 		return false // throw err maybe?
 	}
 
@@ -105,12 +145,9 @@ func (g *Game) Move(cell string) bool {
 		return false
 	}
 
-	if g.Turn == "X" {
-		g.Turn = "O"
-	} else {
-		g.Turn = "X"
-	}
+	g.Turn = Opponent(g.Turn)
 
+	g.History = append(g.History, cellInt)
 	g.Moves++
 
 	g.CheckWinner()
@@ -118,6 +155,37 @@ func (g *Game) Move(cell string) bool {
 	return true
 }
 
+// Undo takes back the last move and records it, it returns false if there is
+// nothing to undo or the game is over.
+func (g *Game) Undo() bool {
+	if len(g.History) == 0 || g.HasWinner() {
+		return false
+	}
+
+	cell := g.History[len(g.History)-1]
+	g.History = g.History[:len(g.History)-1]
+	g.Turn = Opponent(g.Turn)
+	g.Board[cell] = strconv.Itoa(cell + 1)
+	g.Takebacks = append(g.Takebacks, Takeback{
+		Player: g.Turn,
+		Cell:   cell,
+		Ply:    g.Moves,
+	})
+	g.Moves--
+	g.Winner = ""
+
+	return true
+}
+
+// LastMover returns the mark of the player who made the last move, or "" if
+// no moves have been made.
+func (g *Game) LastMover() string {
+	if len(g.History) == 0 {
+		return ""
+	}
+	return Opponent(g.Turn)
+}
+
 func (g *Game) Print() {
 	for i := 0; i < 9; i++ {
 		fmt.Print(g.Board[i] + " ")
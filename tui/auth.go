@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+
+	"github.com/tylerolson/tictacgo/rest"
+	"github.com/tylerolson/tictacgo/server"
+)
+
+// api talks to the REST server, it carries the session token once logged in.
+var api = rest.NewClient(defaultServerURL)
+
+// session is the logged in account, empty when playing as a guest.
+var session server.SessionContent
+
+func register(username string, password string) error {
+	return api.Register(context.Background(), username, password)
+}
+
+func login(username string, password string) (server.SessionContent, error) {
+	return api.Login(context.Background(), username, password)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tylerolson/tictacgo/rest"
+	"github.com/tylerolson/tictacgo/server"
+)
+
+const (
+	defaultServerURL = "http://127.0.0.1:8081"
+	// defaultRESTPort is used for server addresses typed without a port.
+	defaultRESTPort = "8081"
+	// serverEnv picks the server when the --server flag isn't given.
+	serverEnv = "TICTACGO_SERVER"
+	// caEnv and pinEnv stand in for the --ca and --pin flags.
+	caEnv  = "TICTACGO_CA"
+	pinEnv = "TICTACGO_PIN"
+
+	maxRecentServers = 5
+)
+
+// serverURL is the REST server the client uses, the game server is found
+// through it. gameAddr is empty until it has been asked for, gameTLS is
+// whether the game server uses TLS. tlsConfig checks the servers' certificates.
+var (
+	serverURL string
+	gameAddr  string
+	gameTLS   bool
+	tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+)
+
+// normalizeServerURL fills in what a typed server address leaves out, so
+// "example.com" becomes "http://example.com:8081".
+func normalizeServerURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", errors.New("enter a server address")
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	u, err := neturl.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return "", fmt.Errorf("%q isn't a server address", raw)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("server addresses start with http:// or https://, not %s://", u.Scheme)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), defaultRESTPort)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	return u.String(), nil
+}
+
+// useServer points the client at a server without contacting it. Switching
+// to another server logs out, since sessions only work where they started.
+func useServer(url string) {
+	if url == serverURL {
+		return
+	}
+
+	serverURL = url
+	gameAddr = ""
+	api = newAPIClient(url)
+	session = server.SessionContent{}
+}
+
+// newAPIClient is a REST client for url that checks certificates with tlsConfig.
+func newAPIClient(url string) *rest.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	client := rest.NewClient(url)
+	client.HTTPClient = &http.Client{Transport: transport}
+	return client
+}
+
+// discoverGameServer asks the REST server where its game server is.
+func discoverGameServer() error {
+	info, err := api.Info(context.Background())
+	if err != nil {
+		return fmt.Errorf("couldn't reach %s\n%w", serverURL, err)
+	}
+
+	gameAddr = gameServerAddr(serverURL, info)
+	gameTLS = info.TLS
+	return nil
+}
+
+// gameServerAddr is the game server's address, on the same host as the REST
+// server at url.
+func gameServerAddr(url string, info server.ServerInfoContent) string {
+	host := url
+	if u, err := neturl.Parse(url); err == nil {
+		host = u.Hostname()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(info.TCPPort))
+}
+
+// connect switches to the server at raw once it has answered, and remembers
+// it. The current server is kept if it can't be reached.
+func connect(raw string) error {
+	url, err := normalizeServerURL(raw)
+	if err != nil {
+		return err
+	}
+
+	info, err := newAPIClient(url).Info(context.Background())
+	if err != nil {
+		return fmt.Errorf("couldn't reach %s\n%w", url, err)
+	}
+
+	useServer(url)
+	gameAddr = gameServerAddr(url, info)
+	gameTLS = info.TLS
+
+	config, err := loadClientConfig()
+	if err != nil {
+		return err
+	}
+	config.addRecent(url)
+	return config.save()
+}
+
+// clientConfig is kept between runs in the user's config directory.
+type clientConfig struct {
+	Servers []string `json:"servers"` // most recently used first
+}
+
+func clientConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("couldn't find a config directory\n%w", err)
+	}
+	return filepath.Join(dir, "tictacgo", "client.json"), nil
+}
+
+// loadClientConfig reads the client config, a missing file is an empty config.
+func loadClientConfig() (clientConfig, error) {
+	var config clientConfig
+
+	path, err := clientConfigPath()
+	if err != nil {
+		return config, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	} else if err != nil {
+		return config, fmt.Errorf("couldn't read client config\n%w", err)
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("client config %s is invalid\n%w", path, err)
+	}
+	return config, nil
+}
+
+func (c clientConfig) save() error {
+	path, err := clientConfigPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("couldn't save client config\n%w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("couldn't save client config\n%w", err)
+	}
+	return nil
+}
+
+// addRecent moves url to the front of the recent servers.
+func (c *clientConfig) addRecent(url string) {
+	c.Servers = slices.DeleteFunc(c.Servers, func(s string) bool {
+		return s == url
+	})
+	c.Servers = slices.Insert(c.Servers, 0, url)
+	if len(c.Servers) > maxRecentServers {
+		c.Servers = c.Servers[:maxRecentServers]
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// connectModel picks the server to play on, typed in or from the recent ones.
+type connectModel struct {
+	input       textinput.Model
+	recent      []string
+	cursor      int // index into recent, -1 while typing a new address
+	connectKeys connectKeyMap
+	err         error
+}
+
+func newConnectModel() connectModel {
+	input := textinput.New()
+	input.Placeholder = "host:port or http://host:port"
+	input.Width = 50
+	input.Focus()
+
+	config, err := loadClientConfig()
+
+	return connectModel{
+		input:       input,
+		recent:      config.Servers,
+		cursor:      -1,
+		connectKeys: connectKeys,
+		err:         err,
+	}
+}
+
+func (m connectModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m connectModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(msg, m.connectKeys.Back):
+			return newMenuModel(), nil
+		case key.Matches(msg, m.connectKeys.Up):
+			if m.cursor < 0 {
+				return m, nil
+			}
+			m.cursor--
+			return m.selectRecent(), nil
+		case key.Matches(msg, m.connectKeys.Down):
+			if m.cursor < len(m.recent)-1 {
+				m.cursor++
+			}
+			return m.selectRecent(), nil
+		case key.Matches(msg, m.connectKeys.Enter):
+			if m.err = connect(m.input.Value()); m.err != nil {
+				return m, nil
+			}
+			return newMenuModel(), nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// selectRecent fills the input with the recent server under the cursor.
+func (m connectModel) selectRecent() connectModel {
+	if m.cursor < 0 {
+		m.input.Reset()
+	} else {
+		m.input.SetValue(m.recent[m.cursor])
+		m.input.CursorEnd()
+	}
+	return m
+}
+
+func (m connectModel) View() string {
+	var s strings.Builder
+
+	s.WriteString("Connect to a server\n\n")
+	s.WriteString("Using " + serverURL + "\n\n")
+	s.WriteString(m.input.View() + "\n\n")
+
+	if len(m.recent) > 0 {
+		s.WriteString("Recent servers:\n")
+		for i, url := range m.recent {
+			cursor := "  "
+			if m.cursor == i {
+				cursor = "> "
+			}
+			s.WriteString(cursor + url + "\n")
+		}
+	}
+
+	s.WriteString("\n\n" + help.New().View(m.connectKeys) + "\n\n")
+
+	errorMsg := ""
+	if m.err != nil {
+		errorMsg = lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render(fmt.Sprintf("%+v", m.err))
+	}
+
+	return lipgloss.NewStyle().Margin(2, 10).Render(s.String() + errorMsg)
+}
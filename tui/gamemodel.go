@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
@@ -17,12 +18,32 @@ type gameModel struct {
 	game       *tictacgo.Game
 	boardTable table.Model
 	gameKeys   gameKeyMap
-	room       string
-	client     *server.Client
+	client     *server.Client // nil for a local game
 	err        error
 }
 
-func newGameModel(room string) gameModel {
+// dialGameServer connects a new client to the game server.
+func dialGameServer() (*server.Client, error) {
+	if gameAddr == "" {
+		if err := discoverGameServer(); err != nil {
+			return nil, err
+		}
+	}
+
+	c := server.NewClient()
+	c.SetAuthToken(session.Token)
+	if gameTLS {
+		c.SetTLSConfig(tlsConfig)
+	}
+	if err := c.EstablishConnection(gameAddr); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// newGameModel plays locally when client is nil, otherwise client should
+// already have sent its join or spectate request.
+func newGameModel(client *server.Client, spectate bool) gameModel {
 	columns := []table.Column{{Title: "", Width: 1}, {Title: "", Width: 1}, {Title: "", Width: 1}}
 	rows := []table.Row{{"1", "2", "3"}, {"4", "5", "6"}, {"7", "8", "9"}}
 	styles := table.Styles{
@@ -37,18 +58,16 @@ func newGameModel(room string) gameModel {
 		game:       tictacgo.NewGame(),
 		boardTable: t,
 		gameKeys:   gameKeys,
-		room:       room,
+		client:     client,
 	}
 
-	if room != "" {
-		c := server.NewClient()
-		if gm.err = c.EstablishConnection("localhost:8080"); gm.err == nil {
-			c.JoinRoom(room)
-			c.Player = "X"
-
-			gm.client = c
-		}
-
+	if client == nil {
+		gm.gameKeys.Resign.SetEnabled(false)
+		gm.gameKeys.Draw.SetEnabled(false)
+		gm.gameKeys.Accept.SetEnabled(false)
+		gm.gameKeys.Decline.SetEnabled(false)
+	} else if spectate {
+		gm.gameKeys = gameKeyMap{Quit: gameKeys.Quit}
 	}
 
 	return gm
@@ -66,6 +85,35 @@ func receiveError(channel chan error) tea.Cmd {
 	}
 }
 
+type clockTickMsg time.Time
+
+func tickClock() tea.Cmd {
+	return tea.Tick(100*time.Millisecond, func(t time.Time) tea.Msg {
+		return clockTickMsg(t)
+	})
+}
+
+// playerName shows the username and rating of the player with mark.
+func (gm gameModel) playerName(mark string) string {
+	username := gm.client.Username(mark)
+	if username == "" {
+		return "guest"
+	}
+
+	if rating, ok := gm.client.Rating(mark); ok {
+		return fmt.Sprintf("%s (%d)", username, rating)
+	}
+	return username
+}
+
+func formatClock(d time.Duration) string {
+	d = d.Truncate(100 * time.Millisecond)
+	if d < 10*time.Second {
+		return fmt.Sprintf("%d:%04.1f", int(d.Minutes()), (d % time.Minute).Seconds())
+	}
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int((d % time.Minute).Seconds()))
+}
+
 func (gm gameModel) getUpdatedTable(board []string) gameModel {
 	r := gm.boardTable.Rows()
 	for i := 0; i < 9; i++ {
@@ -76,15 +124,22 @@ func (gm gameModel) getUpdatedTable(board []string) gameModel {
 }
 
 func (gm gameModel) Init() tea.Cmd {
+	if gm.client == nil {
+		return nil
+	}
+
 	upCmd := receiveUpdate(gm.client.GetUpdateChannel())
 	errCmd := receiveError(gm.client.GetErrorChannel())
-	return tea.Batch(upCmd, errCmd)
+	return tea.Batch(upCmd, errCmd, tickClock())
 }
 
 func (gm gameModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case error:
 		gm.err = msg
+		return gm, receiveError(gm.client.GetErrorChannel())
+	case clockTickMsg:
+		return gm, tickClock()
 	case server.Response:
 		switch msg.Type {
 		case server.UpdateGame:
@@ -94,15 +149,16 @@ func (gm gameModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch {
 		case key.Matches(msg, gm.gameKeys.Quit):
-			if gm.room == "" {
+			if gm.client == nil {
 				return newMenuModel(), nil
 			} else {
+				gm.client.LeaveRoom()
 				gm.client.CloseConnection()
 				rm := newRoomModel()
 				return rm, rm.Init()
 			}
 		case key.Matches(msg, gm.gameKeys.Move):
-			if gm.room != "" {
+			if gm.client != nil {
 				gm.err = gm.client.MakeMove(msg.String())
 				return gm, nil
 			}
@@ -111,22 +167,110 @@ func (gm gameModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				gm = gm.getUpdatedTable(gm.game.Board)
 				return gm, nil
 			}
+		case key.Matches(msg, gm.gameKeys.Undo):
+			if gm.client == nil {
+				if !gm.game.HasWinner() && gm.game.Undo() {
+					gm = gm.getUpdatedTable(gm.game.Board)
+				}
+				return gm, nil
+			}
+
+			gm.err = gm.client.RequestTakeback()
+		case key.Matches(msg, gm.gameKeys.Resign):
+			gm.err = gm.client.Resign()
+		case key.Matches(msg, gm.gameKeys.Draw):
+			gm.err = gm.client.OfferDraw()
+		case key.Matches(msg, gm.gameKeys.Accept):
+			if offer := gm.client.DrawOffer(); offer != "" && offer != gm.client.Player {
+				gm.err = gm.client.AcceptDraw()
+			} else if offer := gm.client.TakebackOffer(); offer != "" && offer != gm.client.Player {
+				gm.err = gm.client.AcceptTakeback()
+			} else if offer := gm.client.RematchOffer(); offer != "" && offer != gm.client.Player {
+				gm.err = gm.client.AcceptRematch()
+			}
+		case key.Matches(msg, gm.gameKeys.Decline):
+			if offer := gm.client.DrawOffer(); offer != "" && offer != gm.client.Player {
+				gm.err = gm.client.DeclineDraw()
+			} else if offer := gm.client.TakebackOffer(); offer != "" && offer != gm.client.Player {
+				gm.err = gm.client.DeclineTakeback()
+			}
+		case key.Matches(msg, gm.gameKeys.Rematch):
+			if gm.client == nil {
+				if gm.game.HasWinner() {
+					gm.game = tictacgo.NewGame()
+					gm = gm.getUpdatedTable(gm.game.Board)
+				}
+				return gm, nil
+			}
+
+			if offer := gm.client.RematchOffer(); offer != "" && offer != gm.client.Player {
+				gm.err = gm.client.AcceptRematch()
+			} else {
+				gm.err = gm.client.RequestRematch()
+			}
 		}
 	}
 
 	return gm, nil
 }
 
+func (gm gameModel) offerPrompt() string {
+	me := gm.client.Player
+
+	switch offer := gm.client.DrawOffer(); {
+	case offer == me:
+		return "\n\nYou offered a draw"
+	case offer != "":
+		return "\n\n" + offer + " offers a draw, accept? (y/n)"
+	}
+
+	switch offer := gm.client.TakebackOffer(); {
+	case offer == me:
+		return "\n\nYou asked to take back your move"
+	case offer != "":
+		return "\n\n" + offer + " wants to take back their move, allow? (y/n)"
+	}
+
+	switch offer := gm.client.RematchOffer(); {
+	case offer == me:
+		return "\n\nWaiting for " + tictacgo.Opponent(me) + " to accept the rematch..."
+	case offer != "":
+		return "\n\n" + offer + " wants a rematch, accept? (y)"
+	}
+
+	return ""
+}
+
 func (gm gameModel) View() string {
 	s := strings.Builder{}
 
 	s.WriteString(gm.boardTable.View() + "\n")
 
+	if gm.client != nil {
+		x, okX := gm.client.Clock("X")
+		o, okO := gm.client.Clock("O")
+		if okX && okO {
+			s.WriteString("X " + formatClock(x) + "   O " + formatClock(o) + "\n\n")
+		}
+
+		if x, o := gm.client.Username("X"), gm.client.Username("O"); x != "" || o != "" {
+			s.WriteString("X: " + gm.playerName("X") + "   O: " + gm.playerName("O") + "\n\n")
+		}
+	}
+
 	game := gm.game
-	if gm.room != "" {
+	if gm.client != nil {
 		game = gm.client.Game
 
-		if !gm.client.IsStarted() {
+		if reason := gm.client.ClosedReason(); reason != "" {
+			s.WriteString("Room closed: " + reason + "\n")
+		} else if gm.client.IsReconnecting() && gm.client.ServerShuttingDown() {
+			s.WriteString("Server is restarting, reconnecting…\n")
+		} else if gm.client.IsReconnecting() {
+			s.WriteString("Reconnecting…\n")
+		} else if gm.client.ServerShuttingDown() {
+			s.WriteString("Server is shutting down\n")
+		} else if !gm.client.IsStarted() {
 			s.WriteString("Waiting for other player...\n")
 		}
 	}
@@ -138,9 +282,20 @@ func (gm gameModel) View() string {
 	} else {
 		s.WriteString(game.Winner + " wins!")
 	}
+	if game.Reason != "" {
+		s.WriteString(" (" + game.Reason + ")")
+	}
 
-	if gm.room != "" {
-		s.WriteString("\nYou are " + gm.client.Player)
+	if gm.client != nil {
+		if gm.client.IsSpectating() {
+			s.WriteString("\nYou are spectating")
+		} else {
+			s.WriteString("\nYou are " + gm.client.Player)
+			s.WriteString(gm.offerPrompt())
+		}
+		if rtt, ok := gm.client.Latency(); ok && !gm.client.IsReconnecting() {
+			s.WriteString(fmt.Sprintf("\nPing %dms", rtt.Milliseconds()))
+		}
 	}
 
 	s.WriteString("\n\n\n" + help.New().View(gm.gameKeys) + "\n\n")
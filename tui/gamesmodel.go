@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tylerolson/tictacgo/rest"
+	"github.com/tylerolson/tictacgo/server"
+)
+
+const gamesPageSize = 10
+
+// gamesModel lists finished games from the archive, newest first.
+type gamesModel struct {
+	table     table.Model
+	games     []server.GameSummary
+	page      int
+	total     int
+	mine      bool // only show the logged in player's games
+	gamesKeys gamesKeyMap
+	err       error
+}
+
+func newGamesModel() gamesModel {
+	columns := []table.Column{
+		{Title: "ID", Width: 6},
+		{Title: "X", Width: 16},
+		{Title: "O", Width: 16},
+		{Title: "Result", Width: 9},
+		{Title: "Finished", Width: 17},
+	}
+
+	t := table.New(
+		table.WithColumns(columns),
+		table.WithFocused(true),
+		table.WithHeight(gamesPageSize+1),
+	)
+
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		BorderBottom(true).
+		Bold(true)
+	t.SetStyles(s)
+
+	gm := gamesModel{
+		table:     t,
+		page:      1,
+		gamesKeys: gamesKeys,
+	}
+	gm.gamesKeys.Mine.SetEnabled(session.Username != "")
+	return gm
+}
+
+func (m gamesModel) Init() tea.Cmd {
+	return m.fetch(m.page)
+}
+
+func (m gamesModel) fetch(page int) tea.Cmd {
+	player := ""
+	if m.mine {
+		player = session.Username
+	}
+	return fetchGames(page, player)
+}
+
+func (m gamesModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case error:
+		m.err = msg
+	case server.GameListContent:
+		m.err = nil
+		m.page = msg.Page
+		m.total = msg.Total
+		m.games = msg.Games
+
+		rows := make([]table.Row, 0, len(msg.Games))
+		for _, game := range msg.Games {
+			rows = append(rows, table.Row{
+				game.ID,
+				playerOrGuest(game.Players["X"]),
+				playerOrGuest(game.Players["O"]),
+				game.Result,
+				game.Finished.Local().Format("2006-01-02 15:04"),
+			})
+		}
+		m.table.SetRows(rows)
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.gamesKeys.Back):
+			return newMenuModel(), nil
+		case key.Matches(msg, m.gamesKeys.Prev):
+			if m.page > 1 {
+				return m, m.fetch(m.page - 1)
+			}
+		case key.Matches(msg, m.gamesKeys.Next):
+			if m.page*gamesPageSize < m.total {
+				return m, m.fetch(m.page + 1)
+			}
+		case key.Matches(msg, m.gamesKeys.Mine):
+			m.mine = !m.mine
+			return m, m.fetch(1)
+		case key.Matches(msg, m.gamesKeys.Refresh):
+			return m, m.fetch(m.page)
+		case key.Matches(msg, m.gamesKeys.Enter):
+			if cursor := m.table.Cursor(); cursor < len(m.games) {
+				rm := newReplayModel(m, m.games[cursor].ID)
+				return rm, rm.Init()
+			}
+		}
+	}
+
+	var cmd tea.Cmd
+	m.table, cmd = m.table.Update(msg)
+	return m, cmd
+}
+
+func (m gamesModel) View() string {
+	var s strings.Builder
+
+	pages := max(1, (m.total+gamesPageSize-1)/gamesPageSize)
+
+	if m.mine {
+		s.WriteString(" Your games:\n\n")
+	} else {
+		s.WriteString(" Past games:\n\n")
+	}
+	s.WriteString(m.table.View() + "\n\n")
+	s.WriteString(fmt.Sprintf(" Page %d of %d\n", m.page, pages))
+	s.WriteString("\n" + help.New().View(m.gamesKeys) + "\n\n")
+
+	errorMsg := ""
+	if m.err != nil {
+		errorMsg = lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render(fmt.Sprintf("%+v", m.err))
+	}
+
+	return lipgloss.NewStyle().Margin(2, 10).Render(s.String() + errorMsg)
+}
+
+func playerOrGuest(username string) string {
+	if username == "" {
+		return "guest"
+	}
+	return username
+}
+
+func fetchGames(page int, player string) tea.Cmd {
+	return func() tea.Msg {
+		content, err := api.Games(context.Background(), rest.GamesQuery{
+			Player:   player,
+			Page:     page,
+			PageSize: gamesPageSize,
+		})
+		if err != nil {
+			return err
+		}
+		return content
+	}
+}
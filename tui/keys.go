@@ -10,17 +10,71 @@ type menuKeyMap struct {
 }
 
 type roomKeyMap struct {
-	Up      key.Binding
-	Down    key.Binding
+	Up       key.Binding
+	Down     key.Binding
+	Refresh  key.Binding
+	Create   key.Binding
+	Watch    key.Binding
+	JoinCode key.Binding
+	Private  key.Binding
+	Cancel   key.Binding
+	Quit     key.Binding
+	Enter    key.Binding
+}
+
+type loginKeyMap struct {
+	Next   key.Binding
+	Switch key.Binding
+	Enter  key.Binding
+	Back   key.Binding
+}
+
+type connectKeyMap struct {
+	Up    key.Binding
+	Down  key.Binding
+	Enter key.Binding
+	Back  key.Binding
+}
+
+type leaderboardKeyMap struct {
+	Prev    key.Binding
+	Next    key.Binding
+	Refresh key.Binding
+	Back    key.Binding
+}
+
+type gamesKeyMap struct {
+	Prev    key.Binding
+	Next    key.Binding
+	Mine    key.Binding
 	Refresh key.Binding
-	Create  key.Binding
-	Quit    key.Binding
 	Enter   key.Binding
+	Back    key.Binding
+}
+
+type replayKeyMap struct {
+	Prev  key.Binding
+	Next  key.Binding
+	Start key.Binding
+	End   key.Binding
+	Jump  key.Binding
+	Back  key.Binding
+}
+
+type matchKeyMap struct {
+	TimeControl key.Binding
+	Cancel      key.Binding
 }
 
 type gameKeyMap struct {
-	Move key.Binding
-	Quit key.Binding
+	Move    key.Binding
+	Undo    key.Binding
+	Resign  key.Binding
+	Draw    key.Binding
+	Accept  key.Binding
+	Decline key.Binding
+	Rematch key.Binding
+	Quit    key.Binding
 }
 
 func (k menuKeyMap) ShortHelp() []key.Binding {
@@ -28,11 +82,35 @@ func (k menuKeyMap) ShortHelp() []key.Binding {
 }
 
 func (k roomKeyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Up, k.Down, k.Refresh, k.Create, k.Enter, k.Quit}
+	return []key.Binding{k.Up, k.Down, k.Refresh, k.Create, k.Watch, k.JoinCode, k.Private, k.Cancel, k.Enter, k.Quit}
+}
+
+func (k loginKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Next, k.Switch, k.Enter, k.Back}
+}
+
+func (k connectKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Up, k.Down, k.Enter, k.Back}
+}
+
+func (k leaderboardKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Prev, k.Next, k.Refresh, k.Back}
+}
+
+func (k gamesKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Prev, k.Next, k.Mine, k.Refresh, k.Enter, k.Back}
+}
+
+func (k replayKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Prev, k.Next, k.Start, k.End, k.Jump, k.Back}
+}
+
+func (k matchKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.TimeControl, k.Cancel}
 }
 
 func (k gameKeyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Move, k.Quit}
+	return []key.Binding{k.Move, k.Undo, k.Resign, k.Draw, k.Accept, k.Decline, k.Rematch, k.Quit}
 }
 
 func (k menuKeyMap) FullHelp() [][]key.Binding {
@@ -43,6 +121,30 @@ func (k roomKeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{}
 }
 
+func (k loginKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{}
+}
+
+func (k connectKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{}
+}
+
+func (k leaderboardKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{}
+}
+
+func (k gamesKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{}
+}
+
+func (k replayKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{}
+}
+
+func (k matchKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{}
+}
+
 func (k gameKeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{}
 }
@@ -83,6 +185,22 @@ var roomKeys = roomKeyMap{
 		key.WithKeys("c"),
 		key.WithHelp("c", "create room"),
 	),
+	Watch: key.NewBinding(
+		key.WithKeys("v"),
+		key.WithHelp("v", "watch room"),
+	),
+	JoinCode: key.NewBinding(
+		key.WithKeys("j"),
+		key.WithHelp("j", "join by code"),
+	),
+	Private: key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("tab", "toggle private"),
+	),
+	Cancel: key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("esc", "cancel"),
+	),
 	Enter: key.NewBinding(
 		key.WithKeys("enter"),
 		key.WithHelp("enter", "make selection"),
@@ -93,11 +211,157 @@ var roomKeys = roomKeyMap{
 	),
 }
 
+var loginKeys = loginKeyMap{
+	Next: key.NewBinding(
+		key.WithKeys("tab", "down", "up"),
+		key.WithHelp("tab", "next field"),
+	),
+	Switch: key.NewBinding(
+		key.WithKeys("ctrl+r"),
+		key.WithHelp("ctrl+r", "log in/register"),
+	),
+	Enter: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "submit"),
+	),
+	Back: key.NewBinding(
+		key.WithKeys("esc", "ctrl+c"),
+		key.WithHelp("esc", "back"),
+	),
+}
+
+var connectKeys = connectKeyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up"),
+		key.WithHelp("↑", "previous server"),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "tab"),
+		key.WithHelp("↓", "recent server"),
+	),
+	Enter: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "connect"),
+	),
+	Back: key.NewBinding(
+		key.WithKeys("esc", "ctrl+c"),
+		key.WithHelp("esc", "back"),
+	),
+}
+
+var leaderboardKeys = leaderboardKeyMap{
+	Prev: key.NewBinding(
+		key.WithKeys("left", "a"),
+		key.WithHelp("←/a", "previous page"),
+	),
+	Next: key.NewBinding(
+		key.WithKeys("right", "d"),
+		key.WithHelp("→/d", "next page"),
+	),
+	Refresh: key.NewBinding(
+		key.WithKeys("r"),
+		key.WithHelp("r", "refresh"),
+	),
+	Back: key.NewBinding(
+		key.WithKeys("q", "esc", "ctrl+c"),
+		key.WithHelp("q", "back"),
+	),
+}
+
+var gamesKeys = gamesKeyMap{
+	Prev: key.NewBinding(
+		key.WithKeys("left", "a"),
+		key.WithHelp("←/a", "previous page"),
+	),
+	Next: key.NewBinding(
+		key.WithKeys("right", "d"),
+		key.WithHelp("→/d", "next page"),
+	),
+	Mine: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "only my games"),
+	),
+	Refresh: key.NewBinding(
+		key.WithKeys("r"),
+		key.WithHelp("r", "refresh"),
+	),
+	Enter: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "replay"),
+	),
+	Back: key.NewBinding(
+		key.WithKeys("q", "esc", "ctrl+c"),
+		key.WithHelp("q", "back"),
+	),
+}
+
+var replayKeys = replayKeyMap{
+	Prev: key.NewBinding(
+		key.WithKeys("left", "a"),
+		key.WithHelp("←/a", "back a move"),
+	),
+	Next: key.NewBinding(
+		key.WithKeys("right", "d"),
+		key.WithHelp("→/d", "forward a move"),
+	),
+	Start: key.NewBinding(
+		key.WithKeys("home", "up"),
+		key.WithHelp("home", "start"),
+	),
+	End: key.NewBinding(
+		key.WithKeys("end", "down"),
+		key.WithHelp("end", "end"),
+	),
+	Jump: key.NewBinding(
+		key.WithKeys("0", "1", "2", "3", "4", "5", "6", "7", "8", "9"),
+		key.WithHelp("0-9", "jump to ply"),
+	),
+	Back: key.NewBinding(
+		key.WithKeys("q", "esc", "ctrl+c"),
+		key.WithHelp("q", "back"),
+	),
+}
+
+var matchKeys = matchKeyMap{
+	TimeControl: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "change time control"),
+	),
+	Cancel: key.NewBinding(
+		key.WithKeys("esc", "q", "ctrl+c"),
+		key.WithHelp("esc/q", "cancel"),
+	),
+}
+
 var gameKeys = gameKeyMap{
 	Move: key.NewBinding(
 		key.WithKeys("1", "2", "3", "4", "5", "6", "7", "8", "9"),
 		key.WithHelp("1-9", "make move"),
 	),
+	Undo: key.NewBinding(
+		key.WithKeys("u"),
+		key.WithHelp("u", "take back"),
+	),
+	Resign: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "resign"),
+	),
+	Draw: key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "offer draw"),
+	),
+	Accept: key.NewBinding(
+		key.WithKeys("y"),
+		key.WithHelp("y", "accept"),
+	),
+	Decline: key.NewBinding(
+		key.WithKeys("n"),
+		key.WithHelp("n", "decline"),
+	),
+	Rematch: key.NewBinding(
+		key.WithKeys("m"),
+		key.WithHelp("m", "rematch"),
+	),
 	Quit: key.NewBinding(
 		key.WithKeys("q", "ctrl+c"),
 		key.WithHelp("q", "quit"),
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tylerolson/tictacgo/server"
+)
+
+const leaderboardPageSize = 10
+
+type leaderboardModel struct {
+	table           table.Model
+	page            int
+	total           int
+	leaderboardKeys leaderboardKeyMap
+	err             error
+}
+
+func newLeaderboardModel() leaderboardModel {
+	columns := []table.Column{
+		{Title: "Rank", Width: 6},
+		{Title: "Player", Width: 20},
+		{Title: "Rating", Width: 8},
+		{Title: "Games", Width: 8},
+	}
+
+	t := table.New(
+		table.WithColumns(columns),
+		table.WithFocused(true),
+		table.WithHeight(leaderboardPageSize+1),
+	)
+
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		BorderBottom(true).
+		Bold(true)
+	t.SetStyles(s)
+
+	return leaderboardModel{
+		table:           t,
+		page:            1,
+		leaderboardKeys: leaderboardKeys,
+	}
+}
+
+func (m leaderboardModel) Init() tea.Cmd {
+	return fetchLeaderboard(m.page)
+}
+
+func (m leaderboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case error:
+		m.err = msg
+	case server.LeaderboardContent:
+		m.err = nil
+		m.page = msg.Page
+		m.total = msg.Total
+
+		rows := make([]table.Row, 0, len(msg.Entries))
+		for _, entry := range msg.Entries {
+			rows = append(rows, table.Row{
+				strconv.Itoa(entry.Rank),
+				entry.Username,
+				strconv.Itoa(int(math.Round(entry.Rating))),
+				strconv.Itoa(entry.Games),
+			})
+		}
+		m.table.SetRows(rows)
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.leaderboardKeys.Back):
+			return newMenuModel(), nil
+		case key.Matches(msg, m.leaderboardKeys.Prev):
+			if m.page > 1 {
+				return m, fetchLeaderboard(m.page - 1)
+			}
+		case key.Matches(msg, m.leaderboardKeys.Next):
+			if m.page*leaderboardPageSize < m.total {
+				return m, fetchLeaderboard(m.page + 1)
+			}
+		case key.Matches(msg, m.leaderboardKeys.Refresh):
+			return m, fetchLeaderboard(m.page)
+		}
+	}
+
+	var cmd tea.Cmd
+	m.table, cmd = m.table.Update(msg)
+	return m, cmd
+}
+
+func (m leaderboardModel) View() string {
+	var s strings.Builder
+
+	pages := max(1, (m.total+leaderboardPageSize-1)/leaderboardPageSize)
+
+	s.WriteString(" Leaderboard:\n\n")
+	s.WriteString(m.table.View() + "\n\n")
+	s.WriteString(fmt.Sprintf(" Page %d of %d\n", m.page, pages))
+	s.WriteString("\n" + help.New().View(m.leaderboardKeys) + "\n\n")
+
+	errorMsg := ""
+	if m.err != nil {
+		errorMsg = lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render(fmt.Sprintf("%+v", m.err))
+	}
+
+	return lipgloss.NewStyle().Margin(2, 10).Render(s.String() + errorMsg)
+}
+
+func fetchLeaderboard(page int) tea.Cmd {
+	return func() tea.Msg {
+		content, err := api.Leaderboard(context.Background(), page, leaderboardPageSize)
+		if err != nil {
+			return err
+		}
+		return content
+	}
+}
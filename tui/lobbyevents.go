@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tylerolson/tictacgo/server"
+)
+
+// lobbyRetryDelay is how long to wait before reopening a lobby stream that ended.
+const lobbyRetryDelay = 2 * time.Second
+
+type lobbyEvent struct {
+	name string
+	data []byte
+}
+
+// lobbyStreamMsg is sent once GET /events is open, cancel closes it.
+type lobbyStreamMsg struct {
+	events <-chan lobbyEvent
+	cancel context.CancelFunc
+}
+
+type lobbyStreamEndedMsg struct{}
+
+type lobbyRetryMsg struct{}
+
+// watchLobby opens the server's lobby event stream.
+func watchLobby() tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithCancel(context.Background())
+		body, err := api.Events(ctx)
+		if err != nil {
+			cancel()
+			return lobbyStreamEndedMsg{}
+		}
+
+		events := make(chan lobbyEvent)
+		go func() {
+			defer close(events)
+			defer body.Close()
+
+			var event lobbyEvent
+			scanner := bufio.NewScanner(body)
+			for scanner.Scan() {
+				line := scanner.Text()
+				switch {
+				case line == "":
+					if event.name == "" {
+						continue
+					}
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						return
+					}
+					event = lobbyEvent{}
+				case strings.HasPrefix(line, "event: "):
+					event.name = strings.TrimPrefix(line, "event: ")
+				case strings.HasPrefix(line, "data: "):
+					event.data = []byte(strings.TrimPrefix(line, "data: "))
+				}
+			}
+		}()
+
+		return lobbyStreamMsg{events: events, cancel: cancel}
+	}
+}
+
+func nextLobbyEvent(events <-chan lobbyEvent) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-events
+		if !ok {
+			return lobbyStreamEndedMsg{}
+		}
+		return event
+	}
+}
+
+func retryLobby() tea.Cmd {
+	return tea.Tick(lobbyRetryDelay, func(time.Time) tea.Msg {
+		return lobbyRetryMsg{}
+	})
+}
+
+func roomRow(room server.RoomResponse) table.Row {
+	return table.Row{room.Name, strconv.Itoa(room.Size), strconv.Itoa(room.Spectators), room.TimeControl}
+}
+
+// applyLobbyEvent updates the room table rows with an event from the lobby stream.
+func applyLobbyEvent(rows []table.Row, event lobbyEvent) []table.Row {
+	rows = slices.DeleteFunc(rows, func(row table.Row) bool {
+		return strings.Contains(row[1], "?") // the placeholder row
+	})
+
+	switch event.name {
+	case server.RoomsEvent:
+		var rooms []server.RoomResponse
+		if json.Unmarshal(event.data, &rooms) != nil {
+			return rows
+		}
+		slices.SortFunc(rooms, func(a, b server.RoomResponse) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+
+		rows = rows[:0]
+		for _, room := range rooms {
+			rows = append(rows, roomRow(room))
+		}
+	case server.RoomCreatedSSE, server.RoomUpdatedSSE, server.RoomClosedSSE:
+		var room server.RoomResponse
+		if json.Unmarshal(event.data, &room) != nil {
+			return rows
+		}
+
+		i := slices.IndexFunc(rows, func(row table.Row) bool {
+			return row[0] == room.Name
+		})
+		switch {
+		case event.name == server.RoomClosedSSE:
+			if i >= 0 {
+				rows = slices.Delete(rows, i, i+1)
+			}
+		case i >= 0:
+			rows[i] = roomRow(room)
+		default:
+			rows = append(rows, roomRow(room))
+		}
+	}
+
+	return rows
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type loginModel struct {
+	inputs    []textinput.Model
+	focused   int
+	registers bool // registering a new account rather than logging in
+	loginKeys loginKeyMap
+	notice    string
+	err       error
+}
+
+func newLoginModel() loginModel {
+	username := textinput.New()
+	username.Placeholder = "Username"
+	username.CharLimit = 20
+	username.Width = 30
+	username.Focus()
+
+	password := textinput.New()
+	password.Placeholder = "Password"
+	password.EchoMode = textinput.EchoPassword
+	password.Width = 30
+
+	return loginModel{
+		inputs:    []textinput.Model{username, password},
+		loginKeys: loginKeys,
+	}
+}
+
+func (m loginModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m loginModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(msg, m.loginKeys.Back):
+			return newMenuModel(), nil
+		case key.Matches(msg, m.loginKeys.Switch):
+			m.registers = !m.registers
+			m.err = nil
+			m.notice = ""
+			return m, nil
+		case key.Matches(msg, m.loginKeys.Next):
+			m.inputs[m.focused].Blur()
+			m.focused = (m.focused + 1) % len(m.inputs)
+			return m, m.inputs[m.focused].Focus()
+		case key.Matches(msg, m.loginKeys.Enter):
+			return m.submit()
+		}
+	}
+
+	var cmd tea.Cmd
+	m.inputs[m.focused], cmd = m.inputs[m.focused].Update(msg)
+	return m, cmd
+}
+
+func (m loginModel) submit() (tea.Model, tea.Cmd) {
+	username := strings.TrimSpace(m.inputs[0].Value())
+	password := m.inputs[1].Value()
+
+	if m.registers {
+		if m.err = register(username, password); m.err != nil {
+			return m, nil
+		}
+		m.registers = false
+		m.notice = "Account created, you can log in now"
+		return m, nil
+	}
+
+	s, err := login(username, password)
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+
+	session = s
+	return newMenuModel(), nil
+}
+
+func (m loginModel) View() string {
+	var s strings.Builder
+
+	if m.registers {
+		s.WriteString("Register a new account\n\n")
+	} else {
+		s.WriteString("Log in\n\n")
+	}
+
+	for _, input := range m.inputs {
+		s.WriteString(input.View() + "\n")
+	}
+
+	if m.notice != "" {
+		s.WriteString("\n" + m.notice + "\n")
+	}
+
+	s.WriteString("\n\n" + help.New().View(m.loginKeys) + "\n\n")
+
+	errorMsg := ""
+	if m.err != nil {
+		errorMsg = lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render(fmt.Sprintf("%+v", m.err))
+	}
+
+	return lipgloss.NewStyle().Margin(2, 10).Render(s.String() + errorMsg)
+}
@@ -1,13 +1,41 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tylerolson/tictacgo/server"
 )
 
 func main() {
+	serverFlag := flag.String("server", os.Getenv(serverEnv), "server URL like http://host:8081, env "+serverEnv+", the last server used if empty")
+	caFlag := flag.String("ca", os.Getenv(caEnv), "PEM file of CAs to trust instead of the system's, env "+caEnv)
+	pinFlag := flag.String("pin", os.Getenv(pinEnv), "SHA-256 fingerprint of the only server certificate to trust, env "+pinEnv)
+	flag.Parse()
+
+	var err error
+	if tlsConfig, err = server.ClientTLSConfig(*caFlag, *pinFlag); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	raw := *serverFlag
+	if raw == "" {
+		raw = defaultServerURL
+		if config, err := loadClientConfig(); err == nil && len(config.Servers) > 0 {
+			raw = config.Servers[0]
+		}
+	}
+
+	url, err := normalizeServerURL(raw)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	useServer(url)
+
 	p := tea.NewProgram(newMenuModel(), tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Alas, there's been an error: %v", err)
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tylerolson/tictacgo/server"
+)
+
+var quickMatchTimeControls = []string{"3+2", "1+0", "5+0", server.NoTimeControl}
+
+type matchModel struct {
+	spinner     spinner.Model
+	matchKeys   matchKeyMap
+	client      *server.Client
+	timeControl int
+	err         error
+}
+
+func newMatchModel() matchModel {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+
+	mm := matchModel{
+		spinner:   s,
+		matchKeys: matchKeys,
+	}
+
+	if mm.client, mm.err = dialGameServer(); mm.err == nil {
+		mm.err = mm.client.QuickMatch("", quickMatchTimeControls[mm.timeControl])
+	}
+
+	return mm
+}
+
+func (mm matchModel) Init() tea.Cmd {
+	if mm.client == nil {
+		return nil
+	}
+
+	upCmd := receiveUpdate(mm.client.GetUpdateChannel())
+	errCmd := receiveError(mm.client.GetErrorChannel())
+	return tea.Batch(mm.spinner.Tick, upCmd, errCmd)
+}
+
+func (mm matchModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case error:
+		mm.err = msg
+		return mm, receiveError(mm.client.GetErrorChannel())
+	case server.Response:
+		if msg.Type == server.AssignMark {
+			gm := newGameModel(mm.client, false)
+			return gm, gm.Init()
+		}
+		return mm, receiveUpdate(mm.client.GetUpdateChannel())
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		mm.spinner, cmd = mm.spinner.Update(msg)
+		return mm, cmd
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, mm.matchKeys.Cancel):
+			if mm.client != nil {
+				mm.client.CancelQuickMatch()
+				mm.client.CloseConnection()
+			}
+			return newMenuModel(), nil
+		case key.Matches(msg, mm.matchKeys.TimeControl):
+			if mm.client != nil {
+				mm.timeControl = (mm.timeControl + 1) % len(quickMatchTimeControls)
+				mm.err = mm.client.QuickMatch("", quickMatchTimeControls[mm.timeControl])
+			}
+		}
+	}
+
+	return mm, nil
+}
+
+func (mm matchModel) View() string {
+	var s strings.Builder
+
+	timeControl := quickMatchTimeControls[mm.timeControl]
+	if timeControl == server.NoTimeControl {
+		timeControl = "no clock"
+	}
+
+	if mm.client != nil {
+		s.WriteString(mm.spinner.View() + " Looking for an opponent (standard, " + timeControl + ")...\n")
+	}
+	s.WriteString("\n\n" + help.New().View(mm.matchKeys) + "\n\n")
+
+	errorMsg := ""
+	if mm.err != nil {
+		errorMsg = lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render(fmt.Sprintf("%+v", mm.err))
+	}
+
+	return lipgloss.NewStyle().Margin(2, 10).Render(s.String() + errorMsg)
+}
@@ -17,7 +17,7 @@ type menuModel struct {
 
 func newMenuModel() menuModel {
 	return menuModel{
-		choices:  []string{"Start Solo", "Multiplayer", "Exit"},
+		choices:  []string{"Start Solo", "Quick Match", "Multiplayer", "Leaderboard", "Past Games", "Log In", "Connect to Server", "Exit"},
 		cursor:   0,
 		menuKeys: menuKeys,
 	}
@@ -43,12 +43,27 @@ func (m menuModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case key.Matches(msg, m.menuKeys.Enter):
 			if m.cursor == 0 { // local
-				gm := newGameModel("")
+				gm := newGameModel(nil, false)
 				return gm, nil // return nil to not look at channel or whatever
-			} else if m.cursor == 1 { // create room
+			} else if m.cursor == 1 { // quick match
+				mm := newMatchModel()
+				return mm, mm.Init()
+			} else if m.cursor == 2 { // create room
 				rm := newRoomModel()
 				return rm, rm.Init()
-			} else if m.cursor == 2 { // exit
+			} else if m.cursor == 3 { // leaderboard
+				lm := newLeaderboardModel()
+				return lm, lm.Init()
+			} else if m.cursor == 4 { // past games
+				gm := newGamesModel()
+				return gm, gm.Init()
+			} else if m.cursor == 5 { // log in
+				lm := newLoginModel()
+				return lm, lm.Init()
+			} else if m.cursor == 6 { // connect
+				cm := newConnectModel()
+				return cm, cm.Init()
+			} else if m.cursor == 7 { // exit
 				return m, tea.Quit
 			}
 		}
@@ -60,6 +75,12 @@ func (m menuModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m menuModel) View() string {
 	var s strings.Builder
 
+	s.WriteString("Server: " + serverURL + "\n")
+	if session.Username != "" {
+		s.WriteString("Logged in as " + session.Username + "\n")
+	}
+	s.WriteString("\n")
+
 	for i, choice := range m.choices {
 		cursor := " "
 		if m.cursor == i {
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tylerolson/tictacgo"
+	"github.com/tylerolson/tictacgo/server"
+)
+
+// replayModel steps through an archived game one ply at a time.
+type replayModel struct {
+	back       gamesModel
+	id         string
+	detail     *server.GameDetailContent
+	cells      []int // board index of every move
+	ply        int   // number of moves shown on the board
+	boardTable table.Model
+	replayKeys replayKeyMap
+	err        error
+}
+
+func newReplayModel(back gamesModel, id string) replayModel {
+	columns := []table.Column{{Title: "", Width: 1}, {Title: "", Width: 1}, {Title: "", Width: 1}}
+	rows := []table.Row{{" ", " ", " "}, {" ", " ", " "}, {" ", " ", " "}}
+	styles := table.Styles{
+		Header:   lipgloss.NewStyle(),
+		Cell:     lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1).Align(lipgloss.Center, lipgloss.Center),
+		Selected: lipgloss.NewStyle(),
+	}
+
+	t := table.New(table.WithColumns(columns), table.WithRows(rows), table.WithHeight(10), table.WithStyles(styles))
+
+	return replayModel{
+		back:       back,
+		id:         id,
+		boardTable: t,
+		replayKeys: replayKeys,
+	}
+}
+
+func (m replayModel) Init() tea.Cmd {
+	return fetchGame(m.id)
+}
+
+// showPly puts the first ply moves on the board.
+func (m replayModel) showPly(ply int) replayModel {
+	m.ply = max(0, min(ply, len(m.cells)))
+
+	game := tictacgo.NewGame()
+	for _, cell := range m.cells[:m.ply] {
+		game.Move(strconv.Itoa(cell + 1))
+	}
+
+	r := m.boardTable.Rows()
+	for i := 0; i < 9; i++ {
+		if game.Board[i] == "X" || game.Board[i] == "O" {
+			r[i/3][i%3] = game.Board[i]
+		} else {
+			r[i/3][i%3] = " "
+		}
+	}
+	m.boardTable.SetRows(r)
+	return m
+}
+
+func (m replayModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case error:
+		m.err = msg
+	case server.GameDetailContent:
+		m.err = nil
+		m.detail = &msg
+		m.cells = make([]int, 0, len(msg.Moves))
+		for _, move := range msg.Moves {
+			cell, ok := tictacgo.ParseCell(move)
+			if !ok {
+				m.err = fmt.Errorf("game has an unreadable move %q", move)
+				return m, nil
+			}
+			m.cells = append(m.cells, cell)
+		}
+		m = m.showPly(0)
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.replayKeys.Back):
+			return m.back, nil
+		case key.Matches(msg, m.replayKeys.Prev):
+			m = m.showPly(m.ply - 1)
+		case key.Matches(msg, m.replayKeys.Next):
+			m = m.showPly(m.ply + 1)
+		case key.Matches(msg, m.replayKeys.Start):
+			m = m.showPly(0)
+		case key.Matches(msg, m.replayKeys.End):
+			m = m.showPly(len(m.cells))
+		case key.Matches(msg, m.replayKeys.Jump):
+			ply, _ := strconv.Atoi(msg.String())
+			m = m.showPly(ply)
+		}
+	}
+
+	return m, nil
+}
+
+func (m replayModel) View() string {
+	var s strings.Builder
+
+	s.WriteString(" Game " + m.id + "\n\n")
+
+	if m.detail != nil {
+		s.WriteString(" X: " + playerOrGuest(m.detail.Players["X"]) + "   O: " + playerOrGuest(m.detail.Players["O"]) + "\n")
+		s.WriteString(m.boardTable.View() + "\n")
+
+		var moves strings.Builder
+		for i, move := range m.detail.Moves {
+			if i%2 == 0 {
+				moves.WriteString(strconv.Itoa(i/2+1) + ". ")
+			}
+			if i == m.ply-1 {
+				move = lipgloss.NewStyle().Bold(true).Underline(true).Render(move)
+			}
+			moves.WriteString(move + " ")
+		}
+		s.WriteString(" " + moves.String() + m.detail.Result + "\n")
+		s.WriteString(fmt.Sprintf(" Ply %d of %d", m.ply, len(m.cells)))
+		if m.ply == len(m.cells) && m.detail.Reason != "" {
+			s.WriteString(" (" + m.detail.Reason + ")")
+		}
+		s.WriteString("\n")
+	} else if m.err == nil {
+		s.WriteString(" Loading…\n")
+	}
+
+	s.WriteString("\n" + help.New().View(m.replayKeys) + "\n\n")
+
+	errorMsg := ""
+	if m.err != nil {
+		errorMsg = lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render(fmt.Sprintf("%+v", m.err))
+	}
+
+	return lipgloss.NewStyle().Margin(2, 10).Render(s.String() + errorMsg)
+}
+
+func fetchGame(id string) tea.Cmd {
+	return func() tea.Msg {
+		content, err := api.Game(context.Background(), id)
+		if err != nil {
+			return err
+		}
+		return content
+	}
+}
@@ -1,11 +1,8 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
-	"strconv"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/help"
@@ -17,22 +14,36 @@ import (
 	"github.com/tylerolson/tictacgo/server"
 )
 
+type inputMode int
+
+const (
+	createInput inputMode = iota
+	codeInput
+)
+
 type roomModel struct {
-	table     table.Model
-	cursor    int
-	roomKeys  roomKeyMap
-	err       error
-	textInput textinput.Model
+	table      table.Model
+	cursor     int
+	roomKeys   roomKeyMap
+	err        error
+	textInput  textinput.Model
+	inputMode  inputMode
+	private    bool
+	inviteCode string
+	lobby      <-chan lobbyEvent  // live room updates, nil until the stream opens
+	stopLobby  context.CancelFunc // closes the lobby stream
 }
 
 func newRoomModel() roomModel {
 	columns := []table.Column{
 		{Title: "Name", Width: 20},
 		{Title: "Players", Width: 10},
+		{Title: "Watching", Width: 10},
+		{Title: "Clock", Width: 10},
 	}
 
 	rows := []table.Row{
-		{"Rooms not avaliable", "?/2"},
+		{"Rooms not avaliable", "?/2", "?", ""},
 	}
 
 	t := table.New(
@@ -68,7 +79,7 @@ func newRoomModel() roomModel {
 }
 
 func (m roomModel) Init() tea.Cmd {
-	return updateTable(m.table)
+	return tea.Batch(updateTable(m.table), watchLobby())
 }
 
 func (m roomModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -78,28 +89,43 @@ func (m roomModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.err = msg
 	case table.Model:
 		m.table = msg
+	case lobbyStreamMsg:
+		m.lobby, m.stopLobby = msg.events, msg.cancel
+		return m, nextLobbyEvent(m.lobby)
+	case lobbyEvent:
+		m.table.SetRows(applyLobbyEvent(m.table.Rows(), msg))
+		return m, nextLobbyEvent(m.lobby)
+	case lobbyStreamEndedMsg:
+		m.lobby = nil
+		return m, retryLobby()
+	case lobbyRetryMsg:
+		return m, watchLobby()
 	case tea.KeyMsg:
-		switch { // TODO add escape to cancel room create
+		if m.textInput.Focused() {
+			return m.updateInput(msg)
+		}
+
+		switch {
 		case key.Matches(msg, m.roomKeys.Quit):
 			return m, tea.Quit
 		case key.Matches(msg, m.roomKeys.Refresh):
 			return m, updateTable(m.table)
 		case key.Matches(msg, m.roomKeys.Create):
-			m.table.Blur()
-			return m, m.textInput.Focus()
+			return m.focusInput(createInput, "Enter room name")
+		case key.Matches(msg, m.roomKeys.JoinCode):
+			return m.focusInput(codeInput, "Enter invite code")
 		case key.Matches(msg, m.roomKeys.Enter):
-			if m.textInput.Focused() {
-				m.err = createRoom(m.textInput.Value())
-				m.textInput.Blur()
-				m.textInput.Reset()
-				m.table.Focus()
-				return m, updateTable(m.table)
+			if row := m.table.SelectedRow(); row != nil && !strings.Contains(row[1], "?") {
+				return m.enterGame(func(c *server.Client) error {
+					return c.JoinRoom(row[0])
+				}, false)
 			}
-			if !strings.Contains(m.table.SelectedRow()[1], "?") {
-				gm := newGameModel(m.table.SelectedRow()[0])
-				return gm, gm.Init()
+		case key.Matches(msg, m.roomKeys.Watch):
+			if row := m.table.SelectedRow(); row != nil && !strings.Contains(row[1], "?") {
+				return m.enterGame(func(c *server.Client) error {
+					return c.SpectateRoom(row[0])
+				}, true)
 			}
-
 		}
 	}
 
@@ -109,6 +135,73 @@ func (m roomModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmd, textCmd, tableCmd)
 }
 
+func (m roomModel) focusInput(mode inputMode, placeholder string) (tea.Model, tea.Cmd) {
+	m.inputMode = mode
+	m.private = false
+	m.textInput.Placeholder = placeholder
+	m.table.Blur()
+	return m, m.textInput.Focus()
+}
+
+func (m roomModel) blurInput() roomModel {
+	m.textInput.Blur()
+	m.textInput.Reset()
+	m.table.Focus()
+	return m
+}
+
+// updateInput handles keys while the create room or invite code input is open.
+func (m roomModel) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.roomKeys.Cancel):
+		return m.blurInput(), nil
+	case key.Matches(msg, m.roomKeys.Private):
+		if m.inputMode == createInput {
+			m.private = !m.private
+		}
+		return m, nil
+	case key.Matches(msg, m.roomKeys.Enter):
+		value := m.textInput.Value()
+		m = m.blurInput()
+
+		if m.inputMode == codeInput {
+			return m.enterGame(func(c *server.Client) error {
+				return c.JoinPrivateRoom("", strings.ToUpper(value), "")
+			}, false)
+		}
+
+		m.inviteCode, m.err = createRoom(value, m.private)
+		return m, updateTable(m.table)
+	}
+
+	var cmd tea.Cmd
+	m.textInput, cmd = m.textInput.Update(msg)
+	return m, cmd
+}
+
+// enterGame connects to the game server, sends the request made by enter and
+// switches to the game screen.
+func (m roomModel) enterGame(enter func(*server.Client) error, spectate bool) (tea.Model, tea.Cmd) {
+	c, err := dialGameServer()
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+
+	if err := enter(c); err != nil {
+		c.CloseConnection()
+		m.err = err
+		return m, nil
+	}
+
+	if m.stopLobby != nil {
+		m.stopLobby()
+	}
+
+	gm := newGameModel(c, spectate)
+	return gm, gm.Init()
+}
+
 func (m roomModel) View() string {
 	var s strings.Builder
 
@@ -117,6 +210,15 @@ func (m roomModel) View() string {
 
 	if m.textInput.Focused() {
 		s.WriteString(m.textInput.View() + "\n")
+		if m.inputMode == createInput {
+			checkbox := "[ ]"
+			if m.private {
+				checkbox = "[x]"
+			}
+			s.WriteString(checkbox + " private\n")
+		}
+	} else if m.inviteCode != "" {
+		s.WriteString("Invite code: " + m.inviteCode + "\n")
 	} else {
 		s.WriteString("\n")
 	}
@@ -133,7 +235,7 @@ func (m roomModel) View() string {
 
 func updateTable(t table.Model) tea.Cmd { // tea.Cmd
 	return func() tea.Msg {
-		rooms, err := getRooms()
+		rooms, err := api.Rooms(context.Background())
 		if err != nil {
 			return err
 		}
@@ -141,7 +243,7 @@ func updateTable(t table.Model) tea.Cmd { // tea.Cmd
 		var rows []table.Row
 
 		for _, v := range rooms {
-			rows = append(rows, table.Row{v.Name, strconv.Itoa(v.Size)})
+			rows = append(rows, roomRow(v))
 		}
 
 		t.SetRows(rows)
@@ -150,52 +252,15 @@ func updateTable(t table.Model) tea.Cmd { // tea.Cmd
 	}
 }
 
-func getRooms() ([]server.RoomResponse, error) {
-	res, err := http.Get("http://127.0.0.1:8081/rooms")
+// createRoom returns the invite code for a private room.
+func createRoom(roomName string, private bool) (string, error) {
+	created, err := api.CreateRoom(context.Background(), server.RoomContent{
+		Room:    roomName,
+		Private: private,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("REST server is not running\n%w", err)
-	}
-	defer res.Body.Close()
-
-	var rawContent json.RawMessage
-	response := server.Response{
-		Content: &rawContent,
-	}
-
-	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode REST response\n%w", err)
-	}
-
-	if response.Type == server.GetRoom {
-		var content []server.RoomResponse
-
-		if err = json.Unmarshal(rawContent, &content); err != nil {
-			return nil, fmt.Errorf("failed to unmarshall REST RoomResponse\n%w", err)
-		}
-
-		return content, err
-	}
-
-	return nil, err
-}
-
-func createRoom(roomName string) error {
-	request := server.Request{
-		Type: server.MakeRoom,
-		Content: server.RoomContent{
-			Room: roomName,
-		},
-	}
-
-	buff := bytes.NewBuffer(make([]byte, 0))
-
-	if err := json.NewEncoder(buff).Encode(request); err != nil {
-		return fmt.Errorf("failed to encode REST request\n%w", err)
-	}
-
-	if _, err := http.Post("http://127.0.0.1:8081/rooms", "application/json", buff); err != nil {
-		return fmt.Errorf("failed to POST CreateRoom\n%w", err)
+		return "", fmt.Errorf("couldn't create room: %w", err)
 	}
 
-	return nil
+	return created.InviteCode, nil
 }